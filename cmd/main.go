@@ -1,18 +1,25 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
+	"os"
+	"os/signal"
 	"sirius_future/internal"
 	"sirius_future/internal/app/handler"
+	"sirius_future/internal/app/migration"
 	"sirius_future/internal/app/repository"
 	"sirius_future/internal/app/service"
 	"sirius_future/internal/app/usecase"
+	"sirius_future/internal/config"
+	"sirius_future/internal/metrics"
+	"sirius_future/internal/redact"
+	"sirius_future/internal/requestctx"
+	"sirius_future/internal/tracing"
+	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/valyala/fasthttp/fasthttpadaptor"
 
 	"github.com/gofiber/fiber/v2"
 
@@ -21,102 +28,183 @@ import (
 
 var DB *gorm.DB
 
-var (
-	httpRequestsTotal = prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Name: "http_requests_total",
-			Help: "Total number of HTTP requests",
-		},
-		[]string{"path", "method", "status"},
-	)
-
-	httpRequestDuration = prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Name:    "http_request_duration_seconds",
-			Help:    "Duration of HTTP requests in seconds",
-			Buckets: prometheus.DefBuckets,
-		},
-		[]string{"path", "method", "status"},
-	)
-)
-
 func prometeus_init() {
-	prometheus.MustRegister(httpRequestsTotal)
-	prometheus.MustRegister(httpRequestDuration)
+	metrics.RegisterHTTPMetrics(prometheus.DefaultRegisterer)
+	usecase.RegisterMetrics(prometheus.DefaultRegisterer)
 }
 
-var jwtSecret = []byte("supersecretkey")
+// newCache builds the cache selected by the cache mode, the redis client is
+// returned for health checks and is nil in memory mode
+func newCache(cfg config.CacheConfig) (service.Cache, *service.RedisService) {
+	switch cfg.Mode {
+	case config.CacheModeRedis:
+		redis := service.NewRedisService(cfg)
+		return redis, redis
+	case config.CacheModeMemory:
+		return service.NewMemoryCache(cfg.LocalCapacity), nil
+	default:
+		redis := service.NewRedisService(cfg)
+		return service.NewTieredCache(redis, service.NewMemoryCache(cfg.LocalCapacity), cfg.RetryAfter), redis
+	}
+}
+
+// newHealthService checks the database, the schema version and redis
+func newHealthService(cfg *config.Config, db *gorm.DB, redis *service.RedisService, logService service.LoggerService) *service.HealthService {
+	checks := []service.HealthCheck{
+		{Name: "database", Critical: true, Check: func(ctx context.Context) error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}},
+		{Name: "migrations", Critical: true, Check: func(ctx context.Context) error {
+			migrator, err := migration.NewMigrator(db.WithContext(ctx), cfg.Database.Driver)
+			if err != nil {
+				return err
+			}
+			return migrator.Check()
+		}},
+	}
+	if redis != nil {
+		checks = append(checks, service.HealthCheck{Name: "redis", Critical: cfg.Health.RedisCritical, Check: redis.Ping})
+	}
+	return service.NewHealthService(cfg.Health.Timeout, logService, checks...)
+}
+
+// loadConfig reads the file named by CONFIG_FILE, if set, and the environment
+func loadConfig() *config.Config {
+	cfg, err := config.Load(os.Getenv("CONFIG_FILE"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	return cfg
+}
 
 func main() {
-	DB = internal.DatabaseInit()
-	logger := service.InitLogger()
-	redisService := service.NewRedisService("localhost:6379")
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+
+	cfg := loadConfig()
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Starting with configuration:\n%s", cfg)
+
+	prometeus_init()
+
+	var err error
+	DB, err = internal.DatabaseInit(cfg.Database)
+	if err != nil {
+		log.Fatalf("Error initializing database: %v", err)
+	}
+	flushTraces, err := tracing.Init(cfg.Tracing)
+	if err != nil {
+		log.Fatalf("Error initializing tracing: %v", err)
+	}
+	redaction := redact.NewPolicy(cfg.Redaction)
+	logger, logSinks := service.InitLogger(cfg.Log, redaction)
+	cache, redis := newCache(cfg.Cache)
 
 	logService := service.NewLoggerService(logger)
+	notifier := service.NewFileNotifier(cfg.Notifier)
+	authService := service.NewAuthService(cfg.Auth)
+	totpService, err := service.NewTOTPService(cfg.TOTP)
+	if err != nil {
+		log.Fatalf("Error initializing TOTP: %v", err)
+	}
 
 	FutureSiriusRepo := repository.NewFutureSiriusRepository(DB, logService)
 	FutureSiriusService := service.NewFutureSiriusService(DB)
-	FutureSiriusUsecase := usecase.NewFutureSiriusUsecase(FutureSiriusRepo, FutureSiriusService, *redisService)
-	FutureSiriusHandler := handler.NewLinkHandler(FutureSiriusUsecase)
-
-	app := fiber.New()
-
-	// Middleware for Prometheus metrics
-	app.Use(func(c *fiber.Ctx) error {
-		start := time.Now()
-		err := c.Next()
-
-		status := c.Response().StatusCode()
-		httpRequestsTotal.WithLabelValues(c.Path(), c.Method(), http.StatusText(status)).Inc()
-		httpRequestDuration.WithLabelValues(c.Path(), c.Method(), http.StatusText(status)).Observe(time.Since(start).Seconds())
-
-		return err
+	FutureSiriusUsecase := usecase.NewFutureSiriusUsecase(FutureSiriusRepo, FutureSiriusService, cache, notifier, authService, totpService, logService)
+	FutureSiriusHandler := handler.NewLinkHandler(FutureSiriusUsecase, FutureSiriusService, logService)
+	HealthHandler := handler.NewHealthHandler(newHealthService(cfg, DB, redis, logService))
+	LogHandler := handler.NewLogHandler(logSinks, logService)
+
+	apiDoc, err := newAPIDoc()
+	if err != nil {
+		log.Fatalf("Error building the API documentation: %v", err)
+	}
+
+	app := fiber.New(fiber.Config{
+		// every error is answered with handler.ErrorResponse, personal data
+		// quoted in the messages is redacted
+		ErrorHandler: redaction.ErrorResponses(handler.ErrorHandler(logService)),
 	})
 
-	// Public routes
-	app.Post("/api/create-link", FutureSiriusHandler.CreateLink)
-	app.Get("/api/check-link/:url", FutureSiriusHandler.CheckTheLink)
-	app.Post("/register", FutureSiriusHandler.CreateUserWithoutLink)
-	/*app.Post("/login", func(c *fiber.Ctx) error {
-		// Simplified authentication
-		type LoginRequest struct {
-			Username string `json:"username"`
-			Password string `json:"password"`
-		}
-		var req LoginRequest
-		if err := c.BodyParser(&req); err != nil {
-			return fiber.ErrBadRequest
-		}
+	// Middleware for Prometheus metrics
+	app.Use(metrics.Middleware)
+	// Middleware for request traces
+	app.Use(tracing.Middleware)
+	// Middleware for request IDs, must run after tracing
+	app.Use(requestctx.Middleware)
+	// Middleware rendering the errors, must be the last one
+	app.Use(handler.RenderErrors)
+
+	registerRoutes(app, FutureSiriusHandler, HealthHandler, LogHandler, apiDoc)
+
+	// Log lines of a request carry its route, see requestctx
+	requestctx.RecordRoutes(app)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.Server.Addr)
+	}()
+
+	select {
+	case err := <-listenErr:
+		log.Printf("Server stopped: %v", err)
+	case <-ctx.Done():
+		log.Println("Shutdown signal received")
+	}
+	stop()
+
+	shutdown(cfg.Server.ShutdownTimeout, app, FutureSiriusUsecase, flushTraces, logSinks, cache, DB)
+}
 
-		// Здесь должна быть проверка пользователя в базе данных
-		// Для примера используем "admin" как username и password
-		if req.Username == "admin" && req.Password == "admin" {
-			token, err := generateJWT(req.Username)
-			if err != nil {
-				return fiber.ErrInternalServerError
-			}
-			return c.JSON(fiber.Map{"token": token})
+// shutdown stops the app in dependency order: the server first, so no new
+// work arrives, then background workers, then the resources they use.
+// The whole shutdown is bounded by timeout.
+func shutdown(timeout time.Duration, app *fiber.App, uc usecase.FutureSiriusUsecase, flushTraces func(context.Context) error, logSinks *service.LogSinks, cache service.Cache, db *gorm.DB) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	log.Printf("Draining in-flight requests (timeout %s)", timeout)
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Printf("Error draining requests: %v", err)
+	}
+
+	log.Println("Stopping background workers")
+	if err := uc.Shutdown(ctx); err != nil {
+		log.Printf("Error stopping background workers: %v", err)
+	}
+
+	log.Println("Flushing traces")
+	if err := flushTraces(ctx); err != nil {
+		log.Printf("Error flushing traces: %v", err)
+	}
+
+	log.Println("Flushing logs")
+	if err := logSinks.Close(); err != nil {
+		log.Printf("Error flushing logs: %v", err)
+	}
+
+	log.Println("Closing cache")
+	if err := cache.Close(); err != nil {
+		log.Printf("Error closing cache: %v", err)
+	}
+
+	log.Println("Closing database")
+	if sqlDB, err := db.DB(); err == nil {
+		if err := sqlDB.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
 		}
+	}
 
-		return fiber.ErrUnauthorized
-	})
-	*/
-	// JWT-protected routes
-
-	app.Get("/api/users", FutureSiriusHandler.GetAllUsers)
-	app.Get("/api/links", FutureSiriusHandler.GetAllLinks)
-	app.Get("/api/get-referrer/:url", FutureSiriusHandler.GetReferrerByUrl)
-
-	app.Post("/api/payments", FutureSiriusHandler.CreatePayment)
-	app.Get("/api/payments", FutureSiriusHandler.GetAllPayments)
-	app.Get("/api/payments/user/:id", FutureSiriusHandler.GetPaymentsByUserID)
-	app.Patch("/api/payments/:id", FutureSiriusHandler.UpdatePayment)
-
-	// Prometheus metrics
-	app.Get("/metrics", func(c *fiber.Ctx) error {
-		fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())(c.Context())
-		return nil
-	})
-
-	log.Fatal(app.Listen(":8080"))
+	log.Println("Shutdown complete")
 }
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sirius_future/internal"
+	"sirius_future/internal/app/migration"
+	"strconv"
+)
+
+const migrateUsage = `usage: sirius_future migrate <command>
+
+commands:
+  up            apply all pending migrations
+  down          revert the last applied migration
+  status        list migrations and whether they are applied
+  to <version>  migrate up or down to the version, 0 reverts everything`
+
+// runMigrate implements the migrate command, the database is configured
+// the same way as for the server
+func runMigrate(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	cfg := loadConfig().Database
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	db, err := internal.DatabaseOpen(cfg)
+	if err != nil {
+		log.Fatalf("Error connecting database: %v", err)
+	}
+	migrator, err := migration.NewMigrator(db, cfg.Driver)
+	if err != nil {
+		log.Fatalf("Error loading migrations: %v", err)
+	}
+
+	var applied []migration.Migration
+	switch args[0] {
+	case "up":
+		applied, err = migrator.Up()
+	case "down":
+		applied, err = migrator.Down()
+	case "to":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+		version, parseErr := strconv.ParseUint(args[1], 10, 32)
+		if parseErr != nil {
+			log.Fatalf("Invalid version %q", args[1])
+		}
+		applied, err = migrator.To(uint(version))
+	case "status":
+		printMigrationStatus(migrator)
+		return
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	for _, m := range applied {
+		fmt.Printf("migrated %d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		log.Fatalf("Migration failed: %v", err)
+	}
+
+	current, err := migrator.Current()
+	if err != nil {
+		log.Fatalf("Error reading schema version: %v", err)
+	}
+	fmt.Printf("schema version %d (latest %d)\n", current, migrator.Latest())
+}
+
+func printMigrationStatus(migrator *migration.Migrator) {
+	statuses, err := migrator.Status()
+	if err != nil {
+		log.Fatalf("Error reading migrations: %v", err)
+	}
+
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		name := status.Name
+		if name == "" {
+			name = "(unknown to this binary)"
+		}
+		fmt.Printf("%4d  %-40s %s\n", status.Version, name, state)
+	}
+}
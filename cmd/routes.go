@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sirius_future/internal/app/handler"
+	"sirius_future/internal/metrics"
+	"sirius_future/internal/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const apiTitle = "sirius_future API"
+
+// undocumentedRoutes serve the documentation itself
+var undocumentedRoutes = []string{"/openapi.json", "/docs"}
+
+func newAPIDoc() (*openapi.Document, error) {
+	return openapi.New(openapi.Info{Title: apiTitle, Version: "1.0.0"}, handler.ErrorResponse{}, handler.Operations()...)
+}
+
+// registerRoutes wires the handlers to their routes. Every route but
+// undocumentedRoutes must be described in handler.Operations, the tests check it.
+func registerRoutes(app *fiber.App, links *handler.LinkHandler, health *handler.HealthHandler, logs *handler.LogHandler, apiDoc *openapi.Document) {
+	// Probes
+	app.Get("/healthz", health.Liveness)
+	app.Get("/readyz", health.Readiness)
+
+	// Public routes
+	app.Get("/api/check-link/:url", links.CheckTheLink)
+	app.Post("/register", links.CreateUserWithoutLink)
+	app.Post("/register/referral", links.CreateUserWithRefferalLink)
+	app.Post("/verify/email", links.VerifyEmail)
+	app.Post("/verify/phone", links.VerifyPhone)
+	app.Post("/verify/resend", links.ResendVerification)
+	app.Post("/auth/login", links.Login)
+	app.Post("/auth/forgot-password", links.ForgotPassword)
+	app.Post("/auth/reset-password", links.ResetPassword)
+	app.Post("/auth/login/2fa", links.LoginMFA)
+	app.Post("/auth/2fa/enroll", links.Authenticate, links.EnrollTOTP)
+	app.Post("/auth/2fa/confirm", links.Authenticate, links.ConfirmTOTP)
+
+	// JWT-protected routes
+	api := app.Group("/api", links.Authenticate)
+
+	api.Post("/create-link", links.CreateLink)
+	api.Get("/users", links.GetAllUsers)
+	api.Get("/links", links.GetAllLinks)
+	api.Get("/get-referrer/:url", links.GetReferrerByUrl)
+
+	api.Post("/payments", links.CreatePayment)
+	api.Get("/payments", links.GetAllPayments)
+	api.Get("/payments/user/:id", links.GetPaymentsByUserID)
+	api.Patch("/payments/:id", links.RequireRole("admin", "finance"), links.RequireMFA, links.UpdatePayment)
+
+	api.Post("/admin/unlock", links.RequireRole("admin"), links.RequireMFA, links.UnlockLogin)
+	api.Get("/admin/log-level", links.RequireRole("admin"), logs.GetLevels)
+	api.Put("/admin/log-level", links.RequireRole("admin"), links.RequireMFA, logs.SetLevel)
+
+	// Prometheus metrics
+	app.Get("/metrics", metrics.Handler)
+
+	// API documentation
+	app.Get("/openapi.json", apiDoc.Handler)
+	app.Get("/docs", openapi.UI(apiTitle, "/openapi.json"))
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"sirius_future/internal/app/handler"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Every registered route must be documented and every documented one registered
+func TestRoutesAreDocumented(t *testing.T) {
+	apiDoc, err := newAPIDoc()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := fiber.New()
+	registerRoutes(app, handler.NewLinkHandler(nil, nil, nil), handler.NewHealthHandler(nil), handler.NewLogHandler(nil, nil), apiDoc)
+
+	if err := apiDoc.Check(app, undocumentedRoutes...); err != nil {
+		t.Fatal(err)
+	}
+}
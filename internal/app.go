@@ -1,18 +1,121 @@
 package internal
 
 import (
-	"sirius_future/internal/app/entity"
+	"errors"
+	"fmt"
+	"log"
+	"sirius_future/internal/app/migration"
+	"sirius_future/internal/config"
+	"sirius_future/internal/tracing"
+	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func DatabaseInit() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("database/test.db"), &gorm.Config{})
+// maxConnectBackoff caps the delay between connection attempts
+const maxConnectBackoff = 30 * time.Second
+
+// DatabaseInit connects and checks the schema version. A schema ahead of the
+// binary is always refused, pending migrations are applied when AutoMigrate is set.
+func DatabaseInit(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	db, err := DatabaseOpen(cfg)
 	if err != nil {
-		panic("failed to connect database")
+		return nil, err
 	}
 
-	db.AutoMigrate(&entity.Link{}, &entity.User{}, &entity.Payment{})
-	return db
+	migrator, err := migration.NewMigrator(db, cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	err = migrator.Check()
+	if errors.Is(err, migration.ErrPendingMigrations) && cfg.AutoMigrate {
+		applied, upErr := migrator.Up()
+		for _, m := range applied {
+			log.Printf("applied migration %d_%s", m.Version, m.Name)
+		}
+		err = upErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return db, nil
+}
+
+// DatabaseOpen connects to the database without touching the schema
+func DatabaseOpen(cfg config.DatabaseConfig) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch cfg.Driver {
+	case config.DriverSQLite:
+		dialector = sqlite.Open(cfg.DSN)
+	case config.DriverPostgres:
+		dialector = postgres.Open(cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unknown database driver %q, expected %s or %s", cfg.Driver, config.DriverSQLite, config.DriverPostgres)
+	}
+
+	db, err := connect(dialector, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+
+	if err := tracing.InstrumentGORM(db); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// connect opens the database and pings it, retrying with exponential backoff
+// so the app survives starting before the database container is ready
+func connect(dialector gorm.Dialector, cfg config.DatabaseConfig) (*gorm.DB, error) {
+	attempts := cfg.ConnectAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := cfg.ConnectBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var db *gorm.DB
+		if db, err = gorm.Open(dialector, &gorm.Config{}); err == nil {
+			if err = ping(db); err == nil {
+				return db, nil
+			}
+		}
+
+		if attempt < attempts {
+			log.Printf("failed to connect database (attempt %d/%d), retrying in %s: %v", attempt, attempts, backoff, err)
+			time.Sleep(backoff)
+			backoff = min(2*backoff, maxConnectBackoff)
+		}
+	}
+
+	return nil, fmt.Errorf("failed to connect database after %d attempts: %w", attempts, err)
+}
+
+func ping(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
 }
@@ -0,0 +1,60 @@
+package dto
+
+// LoginRequest is the body of POST /auth/login
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required" pii:"email"`
+	Password string `json:"password" validate:"required" pii:"secret"`
+}
+
+// LoginResponse carries the access token, or the token of the second step
+// when the user has two-factor authentication enabled
+type LoginResponse struct {
+	Token       string `json:"token,omitempty"`
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// LoginMFARequest is the body of POST /auth/login/2fa, the code is a TOTP
+// code or a recovery code
+type LoginMFARequest struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type TokenResponse struct {
+	Token string `json:"token"`
+}
+
+type TOTPEnrollmentResponse struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// ConfirmTOTPRequest is the body of POST /auth/2fa/confirm
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// ConfirmTOTPResponse carries the recovery codes, they are shown only once
+type ConfirmTOTPResponse struct {
+	Result        string   `json:"result"`
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ForgotPasswordRequest is the body of POST /auth/forgot-password
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required" pii:"email"`
+}
+
+// ResetPasswordRequest is the body of POST /auth/reset-password, the password
+// rules are checked with the token
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" pii:"secret"`
+}
+
+// UnlockLoginRequest is the body of POST /api/admin/unlock
+type UnlockLoginRequest struct {
+	Email string `json:"email" validate:"required_without=IP" pii:"email"`
+	IP    string `json:"ip" validate:"required_without=Email,omitempty,ip"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+import "sirius_future/internal/app/entity"
+
+// ResultResponse is the body of the endpoints that only report success
+type ResultResponse struct {
+	Result string `json:"result"`
+}
+
+// mapPage converts the items of a page, the cursor and the total are kept
+func mapPage[T any, R any](page *entity.Page[T], convert func(*T) R) *entity.Page[R] {
+	items := make([]R, len(page.Items))
+	for i := range page.Items {
+		items[i] = convert(&page.Items[i])
+	}
+	return &entity.Page[R]{Items: items, NextCursor: page.NextCursor, Total: page.Total}
+}
@@ -0,0 +1,52 @@
+package dto
+
+import (
+	"sirius_future/internal/app/entity"
+	"time"
+)
+
+// CreateLinkRequest is the body of POST /api/create-link, the link is
+// created for the user of the token
+type CreateLinkRequest struct {
+	Limit uint `json:"link_limit" validate:"required,min=1,max=10000"` // a link with no uses left is useless
+}
+
+// CreateLinkResponse carries the url of the new link
+type CreateLinkResponse struct {
+	Link string `json:"Link"`
+}
+
+// CheckLinkResponse tells whether the link can still be used to register
+type CheckLinkResponse struct {
+	Result bool `json:"result"`
+}
+
+// LinkResponse keeps the field names the API served the links with before
+// it had DTOs
+type LinkResponse struct {
+	ID         uint      `json:"ID"`
+	CreatedAt  time.Time `json:"CreatedAt"`
+	UpdatedAt  time.Time `json:"UpdatedAt"`
+	Url        string    `json:"link"`
+	ReferrerID uint      `json:"userID"`
+	Count      uint      `json:"count"`
+	Status     bool      `json:"status"`
+	Limit      uint      `json:"limit"`
+}
+
+func NewLinkResponse(link *entity.Link) LinkResponse {
+	return LinkResponse{
+		ID:         link.ID,
+		CreatedAt:  link.CreatedAt,
+		UpdatedAt:  link.UpdatedAt,
+		Url:        link.Url,
+		ReferrerID: link.ReferrerID,
+		Count:      link.Count,
+		Status:     link.Status,
+		Limit:      link.Limit,
+	}
+}
+
+func NewLinkPage(page *entity.Page[entity.Link]) *entity.Page[LinkResponse] {
+	return mapPage(page, NewLinkResponse)
+}
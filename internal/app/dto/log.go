@@ -0,0 +1,8 @@
+package dto
+
+// SetLogLevelRequest is the body of PUT /api/admin/log-level, no sink
+// changes the level of all sinks
+type SetLogLevelRequest struct {
+	Sink  string `json:"sink" validate:"omitempty,oneof=file stdout"`
+	Level string `json:"level" validate:"required,oneof=debug info warn error"`
+}
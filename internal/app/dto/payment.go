@@ -0,0 +1,90 @@
+package dto
+
+import (
+	"sirius_future/internal/app/entity"
+	"strings"
+	"time"
+)
+
+// CreatePaymentRequest is the body of POST /api/payments
+type CreatePaymentRequest struct {
+	UserID      uint    `json:"user_id" validate:"required,user_exists"`
+	Amount      float64 `json:"amount" validate:"gt=0"`
+	Description string  `json:"description" validate:"required,max=255"`
+	Currency    string  `json:"currency" validate:"omitempty,iso4217"`
+	Status      string  `json:"status" validate:"omitempty,oneof=pending completed failed refunded"`
+}
+
+func (r *CreatePaymentRequest) Normalize() {
+	r.Currency = strings.ToUpper(strings.TrimSpace(r.Currency))
+	r.Description = strings.TrimSpace(r.Description)
+}
+
+func (r *CreatePaymentRequest) Payment() *entity.Payment {
+	return &entity.Payment{
+		UserID:      r.UserID,
+		Amount:      r.Amount,
+		Description: r.Description,
+		Currency:    r.Currency,
+		Status:      r.Status,
+	}
+}
+
+// UpdatePaymentRequest is the body of PATCH /api/payments/:id, only the
+// fields that are set are changed
+type UpdatePaymentRequest struct {
+	Amount      *float64 `json:"amount" validate:"required_without_all=Description Status,omitempty,gt=0"`
+	Description *string  `json:"description" validate:"omitempty,min=1,max=255"`
+	Status      *string  `json:"status" validate:"omitempty,oneof=pending completed failed refunded"`
+}
+
+func (r *UpdatePaymentRequest) Normalize() {
+	if r.Description != nil {
+		description := strings.TrimSpace(*r.Description)
+		r.Description = &description
+	}
+}
+
+func (r *UpdatePaymentRequest) Payment() *entity.Payment {
+	var payment entity.Payment
+	if r.Amount != nil {
+		payment.Amount = *r.Amount
+	}
+	if r.Description != nil {
+		payment.Description = *r.Description
+	}
+	if r.Status != nil {
+		payment.Status = *r.Status
+	}
+	return &payment
+}
+
+// PaymentResponse keeps the field names the API served the payments with
+// before it had DTOs, the user is no longer embedded
+type PaymentResponse struct {
+	ID          uint      `json:"ID"`
+	UserID      uint      `json:"user_id"`
+	Amount      float64   `json:"amount"`
+	Description string    `json:"description"`
+	Currency    string    `json:"currency"`
+	Status      string    `json:"Status"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func NewPaymentResponse(payment *entity.Payment) PaymentResponse {
+	return PaymentResponse{
+		ID:          payment.ID,
+		UserID:      payment.UserID,
+		Amount:      payment.Amount,
+		Description: payment.Description,
+		Currency:    payment.Currency,
+		Status:      payment.Status,
+		CreatedAt:   payment.CreatedAt,
+		UpdatedAt:   payment.UpdatedAt,
+	}
+}
+
+func NewPaymentPage(page *entity.Page[entity.Payment]) *entity.Page[PaymentResponse] {
+	return mapPage(page, NewPaymentResponse)
+}
@@ -0,0 +1,95 @@
+package dto
+
+import (
+	"sirius_future/internal/app/entity"
+	"time"
+)
+
+// RegisterRequest is the body of POST /register. The id, status, referrer and
+// timestamps are set by the server. Only the client roles can be chosen, the
+// staff roles (admin, finance and any added later) are assigned by admins.
+type RegisterRequest struct {
+	Firstname  string `json:"first_name" validate:"required,min=2,max=50" pii:"name"`
+	Secondname string `json:"second_name" validate:"required,min=2,max=50" pii:"name"`
+	Lastname   string `json:"last_name" validate:"required,min=2,max=50" pii:"name"`
+	Email      string `json:"email" validate:"required,email" pii:"email"`
+	Password   string `json:"password" validate:"required,min=2,max=50" pii:"secret"`
+	Phone      string `json:"phone" validate:"required,e164" pii:"phone"`
+	Role       string `json:"role" validate:"required,oneof=parent student"`
+}
+
+func (r *RegisterRequest) Normalize() {
+	r.Email = entity.NormalizeEmail(r.Email)
+	r.Phone = entity.NormalizePhone(r.Phone)
+}
+
+func (r *RegisterRequest) User() *entity.User {
+	return &entity.User{
+		Firstname:  r.Firstname,
+		Secondname: r.Secondname,
+		Lastname:   r.Lastname,
+		Email:      r.Email,
+		Password:   r.Password,
+		Phone:      r.Phone,
+		Role:       r.Role,
+	}
+}
+
+// RegisterReferralRequest is the body of POST /register/referral
+type RegisterReferralRequest struct {
+	User RegisterRequest `json:"user"`
+	Url  string          `json:"url" validate:"required"`
+}
+
+func (r *RegisterReferralRequest) Normalize() {
+	r.User.Normalize()
+}
+
+// RegisterResponse is returned once the user is created, the account is
+// active after the email and the phone are confirmed
+type RegisterResponse struct {
+	Result string `json:"result"`
+	UserID uint   `json:"user_id"`
+}
+
+// UserResponse keeps the field names the API served the users with before
+// it had DTOs
+type UserResponse struct {
+	ID              uint       `json:"ID"`
+	CreatedAt       time.Time  `json:"CreatedAt"`
+	UpdatedAt       time.Time  `json:"UpdatedAt"`
+	Firstname       string     `json:"first_name" pii:"name"`
+	Secondname      string     `json:"second_name" pii:"name"`
+	Lastname        string     `json:"last_name" pii:"name"`
+	Email           string     `json:"email" pii:"email"`
+	Phone           string     `json:"phone" pii:"phone"`
+	Role            string     `json:"role"`
+	ReferrerID      uint       `json:"referrer_id"`
+	Status          string     `json:"status"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+	TOTPEnabled     bool       `json:"totp_enabled"`
+}
+
+func NewUserResponse(user *entity.User) UserResponse {
+	return UserResponse{
+		ID:              user.ID,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+		Firstname:       user.Firstname,
+		Secondname:      user.Secondname,
+		Lastname:        user.Lastname,
+		Email:           user.Email,
+		Phone:           user.Phone,
+		Role:            user.Role,
+		ReferrerID:      user.ReferrerID,
+		Status:          user.Status,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		PhoneVerifiedAt: user.PhoneVerifiedAt,
+		TOTPEnabled:     user.TOTPEnabled,
+	}
+}
+
+func NewUserPage(page *entity.Page[entity.User]) *entity.Page[UserResponse] {
+	return mapPage(page, NewUserResponse)
+}
@@ -0,0 +1,24 @@
+package dto
+
+// VerifyEmailRequest is the body of POST /verify/email
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// VerifyPhoneRequest is the body of POST /verify/phone
+type VerifyPhoneRequest struct {
+	UserID uint   `json:"user_id" validate:"required"`
+	Code   string `json:"code" validate:"required"`
+}
+
+// ResendVerificationRequest is the body of POST /verify/resend
+type ResendVerificationRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+// VerificationResponse carries the status of the user, active once both
+// the email and the phone are confirmed
+type VerificationResponse struct {
+	Result string `json:"result"`
+	Status string `json:"status"`
+}
@@ -0,0 +1,82 @@
+package entity
+
+import (
+	"fmt"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes of the API, each is answered with one HTTP status
+const (
+	CodeValidation    = "validation"
+	CodeUnauthorized  = "unauthorized"
+	CodeForbidden     = "forbidden"
+	CodeNotFound      = "not_found"
+	CodeConflict      = "conflict"
+	CodeLimitExceeded = "limit_exceeded"
+	CodeInternal      = "internal"
+)
+
+// FieldError describes what is wrong with one field of the request, Rule
+// is the broken validation rule when the validator found the error
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Rule    string `json:"rule,omitempty"`
+
+	rule validator.FieldError // translates the message, see Error.Localize
+}
+
+// Error is a domain error. Its code and message are shown to clients, the
+// cause is only logged.
+type Error struct {
+	Code    string
+	Message string
+	Details []FieldError
+	Err     error
+}
+
+func NewError(code string, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NotFound reports a missing record, cause is usually gorm.ErrRecordNotFound
+func NotFound(what string, cause error) *Error {
+	return &Error{Code: CodeNotFound, Message: what + " not found", Err: cause}
+}
+
+// Validation reports an invalid request, details name the fields at fault
+func Validation(message string, details ...FieldError) *Error {
+	return &Error{Code: CodeValidation, Message: message, Details: details}
+}
+
+// InvalidField reports one invalid field of the request
+func InvalidField(field string, format string, args ...any) *Error {
+	message := fmt.Sprintf(format, args...)
+	return Validation(field+" "+message, FieldError{Field: field, Message: message})
+}
+
+// InvalidBody reports a request body that can't be parsed
+func InvalidBody(cause error) *Error {
+	return &Error{Code: CodeValidation, Message: "invalid request body", Err: cause}
+}
+
+// Conflict reports a unique field (email, phone, ...) that is already taken
+func Conflict(field string) *Error {
+	return &Error{
+		Code:    CodeConflict,
+		Message: field + " already exists",
+		Details: []FieldError{{Field: field, Message: "already exists"}},
+	}
+}
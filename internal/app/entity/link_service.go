@@ -1,12 +1,10 @@
 package entity
 
 import (
-	"github.com/go-playground/validator/v10"
-	"gorm.io/gorm"
-)
+	"strings"
+	"time"
 
-var (
-	SecretKey = []byte("3278yd&8327dh32*(@#$E(2")
+	"gorm.io/gorm"
 )
 
 type Link struct {
@@ -19,17 +17,39 @@ type Link struct {
 	Limit      uint   `json:"limit"`
 }
 
+// Outcomes of a referral link redemption
+const (
+	LinkRedemptionSuccess   = "success"
+	LinkRedemptionExhausted = "exhausted" // the usage limit is reached
+	LinkRedemptionDisabled  = "disabled"
+	LinkRedemptionUnknown   = "unknown" // no such link
+)
+
+var ErrLinkUnavailable = InvalidField("url", "is not a valid referral link or its usage limit is reached")
+
+// User fields tagged pii are redacted in logs and error responses, see package redact.
+// Users are cached as JSON, so the password hash and the TOTP secret are never serialized.
 type User struct {
 	gorm.Model
 	ID         uint   `gorm:"primaryKey"`
-	Firstname  string `gorm:"not null" json:"first_name" validate:"required,min=2,max=50"`
-	Secondname string `gorm:"not null" json:"second_name" validate:"required,min=2,max=50"`
-	Lastname   string `gorm:"not null" json:"last_name" validate:"required,min=2,max=50"`
-	Email      string `gorm:"not null" json:"email" validate:"required,email"`
-	Password   string `gorm:"not null' json:"password" validate:"required,min=2,max=50"`
-	Phone      string `gorm:"not null" json:"phone" validate:"required,e164"`
+	Firstname  string `gorm:"not null" json:"first_name" validate:"required,min=2,max=50" pii:"name"`
+	Secondname string `gorm:"not null" json:"second_name" validate:"required,min=2,max=50" pii:"name"`
+	Lastname   string `gorm:"not null" json:"last_name" validate:"required,min=2,max=50" pii:"name"`
+	Email      string `gorm:"not null;uniqueIndex:idx_users_email,where:deleted_at IS NULL" json:"email" validate:"required,email" pii:"email"`
+	Password   string `gorm:"not null" json:"-" validate:"required,min=2,max=50" pii:"secret"`
+	Phone      string `gorm:"not null;uniqueIndex:idx_users_phone,where:deleted_at IS NULL" json:"phone" validate:"required,e164" pii:"phone"`
 	Role       string `gorm:"not null" json:"role" validate:"required"`
 	ReferrerID uint   `json:"referrer_id"`
+
+	Status          string     `gorm:"not null;default:pending" json:"status"` // pending until both email and phone are confirmed
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at"`
+
+	TokenVersion uint `gorm:"not null;default:0" json:"-"` // bumped to revoke all issued access tokens
+
+	TOTPSecret   string `json:"-" pii:"secret"` // encrypted, see service.TOTPService
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `json:"-"` // last accepted time step, protects from code replay
 }
 
 type Payment struct {
@@ -37,12 +57,34 @@ type Payment struct {
 	UserID      uint    `gorm:"not null" json:"user_id"`
 	Amount      float64 `gorm:"not null" json:"amount"`
 	Description string  `gorm:"not null" json:"description"`
-	User        User    `gorm:"foreignKey:UserID"`
+	Currency    string  `gorm:"not null;default:RUB" json:"currency"` // ISO 4217 code
+	User        User    `gorm:"foreignKey:UserID" json:"-"`           // not loaded, kept for the relation
 	Status      string  `gorm:"not null"`
+
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const (
+	PaymentStatusPending   = "pending"
+	PaymentStatusCompleted = "completed"
+	PaymentStatusFailed    = "failed"
+	PaymentStatusRefunded  = "refunded"
+
+	DefaultCurrency = "RUB"
+)
+
+// IsKnownPaymentStatus reports whether the status is one of PaymentStatus*
+func IsKnownPaymentStatus(status string) bool {
+	switch status {
+	case PaymentStatusPending, PaymentStatusCompleted, PaymentStatusFailed, PaymentStatusRefunded:
+		return true
+	}
+	return false
 }
 
 type JWTCredentials struct {
-	UserID     uint   `user_id`
+	UserID     uint   `json:"user_id"`
 	Firstname  string `json:"first_name" `
 	Secondname string `json:"second_name" `
 	Lastname   string `json:"last_name"`
@@ -53,8 +95,54 @@ type JWTCredentials struct {
 	ReferrerID uint   `json:"referrer_id"`
 }
 
-var validate = validator.New()
-
 func (u *User) Validate() error {
 	return validate.Struct(u)
 }
+
+// ValidatePassword applies the same rules as User.Password
+func ValidatePassword(password string) error {
+	return validate.Var(password, "required,min=2,max=50")
+}
+
+// Normalize brings email and phone to the canonical form they are stored and compared in
+func (u *User) Normalize() {
+	u.Email = NormalizeEmail(u.Email)
+	u.Phone = NormalizePhone(u.Phone)
+}
+
+// NormalizeEmail trims whitespace and lower-cases the address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// NormalizePhone converts a phone number to E.164 (+79991234567).
+// Spaces, dashes, dots and brackets are dropped, the "00" international
+// prefix becomes "+", the local russian "8XXXXXXXXXX" becomes "+7XXXXXXXXXX"
+// and a bare russian mobile number "9XXXXXXXXX" (10 digits) becomes "+79XXXXXXXXX".
+// The result is not validated here, that is done by the e164 rule.
+func NormalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	if phone == "" {
+		return phone
+	}
+
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	number := digits.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case len(number) == 11 && number[0] == '8':
+		number = "7" + number[1:]
+	case len(number) == 10 && number[0] == '9':
+		number = "7" + number
+	}
+
+	return "+" + number
+}
@@ -0,0 +1,88 @@
+package entity
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// Users and payments are cached as JSON, the secrets must not be written
+func TestUserSecretsAreNotSerialized(t *testing.T) {
+	const (
+		hash   = "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"
+		secret = "encrypted-totp-secret"
+	)
+	user := User{ID: 7, Email: "ivan@mail.ru", Password: hash, TOTPSecret: secret, TOTPEnabled: true}
+
+	values := map[string]any{
+		"user":         user,
+		"user page":    Page[User]{Items: []User{user}},
+		"payment page": Page[Payment]{Items: []Payment{{ID: 3, UserID: user.ID, User: user}}},
+	}
+	for name, value := range values {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, leaked := range []string{hash, secret} {
+			if strings.Contains(string(data), leaked) {
+				t.Errorf("%s contains %q: %s", name, leaked, data)
+			}
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string
+	}{
+		{"+79991234567", "+79991234567"},
+		{"+7 (999) 123-45-67", "+79991234567"},
+		{" +7.999.123.45.67 ", "+79991234567"},
+		{"89991234567", "+79991234567"},
+		{"8 (999) 123-45-67", "+79991234567"},
+		{"9991234567", "+79991234567"},
+		{"999 123 45 67", "+79991234567"},
+		{"0079991234567", "+79991234567"},
+		{"00 44 20 7946 0958", "+442079460958"},
+		{"+44 20 7946 0958", "+442079460958"},
+		{"+8 999 123 45 67", "+89991234567"}, // explicit international number, kept
+		{"79991234567", "+79991234567"},
+		{"4991234567", "+4991234567"}, // 10 digits not starting with 9, left to the e164 rule
+		{"", ""},
+		{"   ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			if got := NormalizePhone(tt.phone); got != tt.want {
+				t.Errorf("NormalizePhone(%q) = %q, want %q", tt.phone, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUserNormalize(t *testing.T) {
+	tests := []struct {
+		name  string
+		user  User
+		email string
+		phone string
+	}{
+		{"canonical", User{Email: "ivan@mail.ru", Phone: "+79991234567"}, "ivan@mail.ru", "+79991234567"},
+		{"case and spaces", User{Email: " Ivan@Mail.RU\t", Phone: "8 (999) 123-45-67"}, "ivan@mail.ru", "+79991234567"},
+		{"bare mobile number", User{Email: "IVAN@MAIL.RU", Phone: "9991234567"}, "ivan@mail.ru", "+79991234567"},
+		{"empty", User{}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := tt.user
+			user.Normalize()
+			if user.Email != tt.email || user.Phone != tt.phone {
+				t.Errorf("Normalize() = %q, %q, want %q, %q", user.Email, user.Phone, tt.email, tt.phone)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package entity
+
+import "time"
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 200
+)
+
+var (
+	ErrInvalidCursor = InvalidField("cursor", "is invalid or was issued for another sort")
+	ErrInvalidSort   = InvalidField("sort", "is not a sortable field")
+)
+
+// PageRequest is the cursor pagination of list endpoints. Sort is one of the
+// whitelisted fields of the list, a "-" prefix sorts descending. Cursor is the
+// NextCursor of the previous page and must be used with the same sort and filters.
+type PageRequest struct {
+	Limit     int    `json:"limit"`
+	Cursor    string `json:"cursor,omitempty"`
+	Sort      string `json:"sort,omitempty"`
+	WithTotal bool   `json:"with_total,omitempty"`
+}
+
+// Page is one page of a list, NextCursor is empty on the last page.
+// Total is set only when requested with PageRequest.WithTotal.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+type UserFilter struct {
+	Role       string `json:"role,omitempty"`
+	ReferrerID *uint  `json:"referrer_id,omitempty"`
+}
+
+type LinkFilter struct {
+	ReferrerID *uint `json:"referrer_id,omitempty"` // owner of the link
+	Status     *bool `json:"status,omitempty"`
+	Exhausted  *bool `json:"exhausted,omitempty"` // usage count reached the limit
+}
+
+type PaymentFilter struct {
+	UserID    *uint      `json:"user_id,omitempty"`
+	Status    string     `json:"status,omitempty"`
+	MinAmount *float64   `json:"min_amount,omitempty"`
+	MaxAmount *float64   `json:"max_amount,omitempty"`
+	From      *time.Time `json:"from,omitempty"`
+	To        *time.Time `json:"to,omitempty"`
+}
@@ -0,0 +1,186 @@
+package entity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	ru_translations "github.com/go-playground/validator/v10/translations/ru"
+)
+
+// Languages of the validation messages, the first one is the default
+const (
+	LanguageEnglish = "en"
+	LanguageRussian = "ru"
+)
+
+var Languages = []string{LanguageEnglish, LanguageRussian}
+
+// messages translates the messages of domain errors, the english text is the key.
+// Messages without a translation are shown in english.
+var messages = map[string]map[string]string{
+	LanguageRussian: {
+		"request is invalid":   "Запрос содержит ошибки",
+		"invalid request body": "Некорректное тело запроса",
+	},
+}
+
+var (
+	validate    = validator.New()
+	translators = ut.New(en.New(), en.New(), ru.New())
+)
+
+func init() {
+	// fields are reported by the names clients send them with
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	english, _ := translators.GetTranslator(LanguageEnglish)
+	russian, _ := translators.GetTranslator(LanguageRussian)
+	if err := en_translations.RegisterDefaultTranslations(validate, english); err != nil {
+		panic(err)
+	}
+	if err := ru_translations.RegisterDefaultTranslations(validate, russian); err != nil {
+		panic(err)
+	}
+	for tag, texts := range ruleMessages {
+		if err := registerMessages(tag, texts); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// ruleMessages completes the default translations: the rules they lack and
+// the russian text of e164, which is left half in english
+var ruleMessages = map[string]map[string]string{
+	"e164": {
+		LanguageRussian: "{0} должен быть номером телефона в формате E.164",
+	},
+	"iso4217": {
+		LanguageEnglish: "{0} must be an ISO 4217 currency code",
+		LanguageRussian: "{0} должен быть кодом валюты ISO 4217",
+	},
+	"required_without": {
+		LanguageEnglish: "{0} is required when the alternative field is empty",
+		LanguageRussian: "{0} обязательное поле, если альтернативное поле не задано",
+	},
+	"required_without_all": {
+		LanguageEnglish: "{0} is required when the other fields are empty",
+		LanguageRussian: "{0} обязательное поле, если остальные поля не заданы",
+	},
+}
+
+// RegisterRule adds a validation rule and its message per language, {0} in
+// the message is the field. Rules must be registered before validation starts.
+func RegisterRule(tag string, rule validator.FuncCtx, texts map[string]string) error {
+	if err := validate.RegisterValidationCtx(tag, rule); err != nil {
+		return err
+	}
+	return registerMessages(tag, texts)
+}
+
+func registerMessages(tag string, texts map[string]string) error {
+	for language, text := range texts {
+		translator, found := translators.GetTranslator(language)
+		if !found {
+			return fmt.Errorf("no translator for language %q", language)
+		}
+		if err := registerTranslation(translator, tag, text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerTranslation(translator ut.Translator, tag string, text string) error {
+	return validate.RegisterTranslation(tag, translator,
+		func(translator ut.Translator) error {
+			return translator.Add(tag, text, true)
+		},
+		func(translator ut.Translator, fieldErr validator.FieldError) string {
+			message, err := translator.T(tag, fieldErr.Field())
+			if err != nil {
+				return fieldErr.Error()
+			}
+			return message
+		})
+}
+
+// ValidateRequest checks the request against its validate tags
+func ValidateRequest(ctx context.Context, request any) error {
+	if err := validate.StructCtx(ctx, request); err != nil {
+		return ValidationFailed(err)
+	}
+	return nil
+}
+
+// ValidationFailed turns the errors of the validator into a validation error
+// listing every field at fault
+func ValidationFailed(err error) error {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+
+	details := make([]FieldError, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		details = append(details, newFieldError(fieldErr))
+	}
+	return Validation("request is invalid", details...)
+}
+
+func newFieldError(fieldErr validator.FieldError) FieldError {
+	english, _ := translators.GetTranslator(LanguageEnglish)
+	return FieldError{
+		Field:   fieldPath(fieldErr),
+		Message: fieldErr.Translate(english),
+		Rule:    fieldErr.Tag(),
+		rule:    fieldErr,
+	}
+}
+
+// fieldPath is the path of the field without the name of the validated
+// struct: "email", "items[0].amount"
+func fieldPath(fieldErr validator.FieldError) string {
+	_, path, found := strings.Cut(fieldErr.Namespace(), ".")
+	if !found {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+// Localize returns the error with its messages in the language, english
+// when the language isn't supported
+func (e *Error) Localize(language string) *Error {
+	translator, found := translators.GetTranslator(language)
+	if !found || language == LanguageEnglish {
+		return e
+	}
+
+	localized := *e
+	if message, ok := messages[language][e.Message]; ok {
+		localized.Message = message
+	}
+	if len(e.Details) > 0 {
+		localized.Details = make([]FieldError, len(e.Details))
+		for i, detail := range e.Details {
+			if detail.rule != nil {
+				detail.Message = detail.rule.Translate(translator)
+			}
+			localized.Details[i] = detail
+		}
+	}
+	return &localized
+}
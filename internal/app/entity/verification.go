@@ -0,0 +1,73 @@
+package entity
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+
+	VerificationChannelEmail = "email"
+	VerificationChannelSMS   = "sms"
+
+	// password reset tokens are stored the same way as verification codes
+	VerificationChannelPasswordReset = "password_reset"
+)
+
+var (
+	ErrVerificationInvalid  = NewError(CodeValidation, "verification code is invalid or expired")
+	ErrVerificationAttempts = NewError(CodeLimitExceeded, "too many verification attempts, try again later")
+	ErrUserAlreadyVerified  = NewError(CodeConflict, "user is already verified")
+
+	ErrInvalidCredentials = NewError(CodeUnauthorized, "invalid email or password")
+	ErrUserNotActive      = NewError(CodeForbidden, "account is not verified")
+	ErrTooManyRequests    = NewError(CodeLimitExceeded, "too many requests, try again later")
+	ErrInvalidPassword    = NewError(CodeValidation, "password must be between 2 and 50 characters")
+
+	ErrTOTPInvalid        = NewError(CodeUnauthorized, "two-factor code is invalid")
+	ErrTOTPNotEnrolled    = NewError(CodeValidation, "two-factor authentication is not enrolled")
+	ErrTOTPAlreadyEnabled = NewError(CodeConflict, "two-factor authentication is already enabled")
+	ErrMFARequired        = NewError(CodeForbidden, "two-factor authentication is required for this operation")
+	ErrAccessDenied       = NewError(CodeForbidden, "access denied")
+)
+
+// Verification is a one-time email token or SMS code. Only the hash of the
+// code is stored.
+type Verification struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	Channel   string    `gorm:"not null"`
+	CodeHash  string    `gorm:"not null;index" pii:"secret"`
+	Attempts  uint      `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+}
+
+// ReferralReward is granted to the referrer once the referred user is verified
+type ReferralReward struct {
+	gorm.Model
+	ReferrerID uint `gorm:"not null;index" json:"referrer_id"`
+	ReferredID uint `gorm:"not null;uniqueIndex" json:"referred_id"`
+}
+
+// RecoveryCode is a single-use backup for the TOTP second factor, only the hash is stored
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"not null" pii:"secret"`
+	UsedAt   *time.Time
+}
+
+// LockedError is returned while logins are throttled or temporarily locked
+// after repeated failures
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %d seconds", int(e.RetryAfter.Seconds()+0.5))
+}
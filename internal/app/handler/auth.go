@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"sirius_future/internal/app/dto"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/requestctx"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (lh *LinkHandler) Login(c *fiber.Ctx) error {
+	var request dto.LoginRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	result, err := lh.usecase.Login(c.UserContext(), request.Email, request.Password, c.IP())
+	if err != nil {
+		return err
+	}
+
+	if result.MFARequired {
+		return c.JSON(dto.LoginResponse{MFARequired: true, MFAToken: result.MFAToken})
+	}
+
+	return c.JSON(dto.LoginResponse{Token: result.Token})
+}
+
+func (lh *LinkHandler) LoginMFA(c *fiber.Ctx) error {
+	var request dto.LoginMFARequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	token, err := lh.usecase.LoginMFA(c.UserContext(), request.MFAToken, request.Code, c.IP())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.TokenResponse{Token: token})
+}
+
+func (lh *LinkHandler) EnrollTOTP(c *fiber.Ctx) error {
+	enrollment, err := lh.usecase.EnrollTOTP(c.UserContext(), c.Locals("user_id").(uint))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.TOTPEnrollmentResponse{
+		Secret:          enrollment.Secret,
+		ProvisioningURI: enrollment.ProvisioningURI,
+	})
+}
+
+func (lh *LinkHandler) ConfirmTOTP(c *fiber.Ctx) error {
+	var request dto.ConfirmTOTPRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	codes, err := lh.usecase.ConfirmTOTP(c.UserContext(), c.Locals("user_id").(uint), request.Code)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.ConfirmTOTPResponse{
+		Result:        "two-factor authentication enabled, log in again to use it",
+		RecoveryCodes: codes,
+	})
+}
+
+func (lh *LinkHandler) ForgotPassword(c *fiber.Ctx) error {
+	var request dto.ForgotPasswordRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	if err := lh.usecase.ForgotPassword(c.UserContext(), request.Email, c.IP()); err != nil {
+		return err
+	}
+
+	return c.JSON(dto.ResultResponse{Result: "if the account exists, a password reset code has been sent"})
+}
+
+func (lh *LinkHandler) ResetPassword(c *fiber.Ctx) error {
+	var request dto.ResetPasswordRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	if err := lh.usecase.ResetPassword(c.UserContext(), request.Token, request.Password); err != nil {
+		return err
+	}
+
+	return c.JSON(dto.ResultResponse{Result: "password successfully changed"})
+}
+
+var errMissingToken = entity.NewError(entity.CodeUnauthorized, "missing bearer token")
+
+// Authenticate is the middleware for JWT-protected routes. It stores the
+// user id and role of the token in c.Locals.
+func (lh *LinkHandler) Authenticate(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	token, found := strings.CutPrefix(header, "Bearer ")
+	if !found || token == "" {
+		return errMissingToken
+	}
+
+	claims, err := lh.usecase.Authenticate(c.UserContext(), token)
+	if err != nil {
+		return err
+	}
+
+	c.Locals("user_id", claims.UserID)
+	c.Locals("role", claims.Role)
+	c.Locals("mfa", claims.MFA)
+	c.SetUserContext(requestctx.WithUserID(c.UserContext(), claims.UserID))
+	return c.Next()
+}
+
+// RequireMFA must run after Authenticate. It rejects users whose role requires
+// two-factor authentication unless they passed it during login.
+func (lh *LinkHandler) RequireMFA(c *fiber.Ctx) error {
+	role, _ := c.Locals("role").(string)
+	mfa, _ := c.Locals("mfa").(bool)
+
+	if lh.usecase.RequiresMFA(role) && !mfa {
+		return entity.ErrMFARequired
+	}
+	return c.Next()
+}
+
+// UnlockLogin lets an admin clear the lockout of an account and/or an ip
+func (lh *LinkHandler) UnlockLogin(c *fiber.Ctx) error {
+	var request dto.UnlockLoginRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	if err := lh.usecase.UnlockLogin(c.UserContext(), request.Email, request.IP); err != nil {
+		return err
+	}
+
+	return c.JSON(dto.ResultResponse{Result: "lockout cleared"})
+}
+
+// RequireRole must run after Authenticate
+func (lh *LinkHandler) RequireRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		role, _ := c.Locals("role").(string)
+		for _, allowed := range roles {
+			if role == allowed {
+				return c.Next()
+			}
+		}
+
+		return entity.ErrAccessDenied
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"errors"
+	"math"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/requestctx"
+	"sirius_future/internal/tracing"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// errorStatus maps the codes of entity.Error to HTTP statuses
+var errorStatus = map[string]int{
+	entity.CodeValidation:    fiber.StatusBadRequest,
+	entity.CodeUnauthorized:  fiber.StatusUnauthorized,
+	entity.CodeForbidden:     fiber.StatusForbidden,
+	entity.CodeNotFound:      fiber.StatusNotFound,
+	entity.CodeConflict:      fiber.StatusConflict,
+	entity.CodeLimitExceeded: fiber.StatusTooManyRequests,
+	entity.CodeInternal:      fiber.StatusInternalServerError,
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Code      string              `json:"code"`
+	Message   string              `json:"message"`
+	Details   []entity.FieldError `json:"details,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+}
+
+// ErrorHandler renders the errors returned by handlers and middleware.
+// Domain errors are answered with their code and message in the language of
+// Accept-Language, fiber errors
+// (unknown route, body too large, ...) with their status. Anything else is
+// an internal error, its text is logged and never sent to the client.
+func ErrorHandler(log service.LoggerService) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		ctx := c.UserContext()
+		response := ErrorResponse{RequestID: requestctx.RequestID(ctx)}
+		status := fiber.StatusInternalServerError
+
+		var (
+			locked    *entity.LockedError
+			domainErr *entity.Error
+			fiberErr  *fiber.Error
+		)
+		switch {
+		case errors.As(err, &locked):
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+			status = fiber.StatusTooManyRequests
+			response.Code, response.Message = entity.CodeLimitExceeded, locked.Error()
+		case errors.As(err, &domainErr):
+			language := c.AcceptsLanguages(entity.Languages...)
+			if language == "" {
+				language = entity.LanguageEnglish
+			}
+			c.Set(fiber.HeaderContentLanguage, language)
+			domainErr = domainErr.Localize(language)
+			if code, ok := errorStatus[domainErr.Code]; ok {
+				status = code
+			}
+			response.Code, response.Message, response.Details = domainErr.Code, domainErr.Message, domainErr.Details
+		case errors.As(err, &fiberErr):
+			status = fiberErr.Code
+			response.Code, response.Message = fiberErrorCode(fiberErr.Code), fiberErr.Message
+		}
+
+		if status >= fiber.StatusInternalServerError {
+			log.ErrorContext(ctx, "Request failed", err, "status", status)
+			tracing.RecordError(trace.SpanFromContext(ctx), err)
+			response.Code, response.Message, response.Details = entity.CodeInternal, "internal server error", nil
+		}
+
+		return c.Status(status).JSON(response)
+	}
+}
+
+// RenderErrors answers the errors of the middleware after it and of the
+// handlers with the ErrorHandler of the app, the only place error responses
+// are rendered. It is the last app middleware, so metrics and tracing, which
+// run before it, see the final status.
+func RenderErrors(c *fiber.Ctx) error {
+	if err := c.Next(); err != nil {
+		if handleErr := c.App().ErrorHandler(c, err); handleErr != nil {
+			return c.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+	return nil
+}
+
+// fiberErrorCode picks the code of an error raised by fiber itself
+func fiberErrorCode(status int) string {
+	for code, codeStatus := range errorStatus {
+		if codeStatus == status {
+			return code
+		}
+	}
+	if status >= fiber.StatusInternalServerError {
+		return entity.CodeInternal
+	}
+	return entity.CodeValidation
+}
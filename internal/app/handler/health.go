@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"sirius_future/internal/app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HealthHandler struct {
+	health *service.HealthService
+}
+
+func NewHealthHandler(health *service.HealthService) *HealthHandler {
+	return &HealthHandler{health: health}
+}
+
+// Liveness reports that the process serves requests, dependencies are not checked
+// so an outage doesn't get the app restarted
+func (hh *HealthHandler) Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"status": service.HealthStatusOK,
+	})
+}
+
+// Readiness checks the dependencies, 503 when a critical one is down
+func (hh *HealthHandler) Readiness(c *fiber.Ctx) error {
+	report := hh.health.Check(c.UserContext())
+	if !report.Ready() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(report)
+	}
+	return c.JSON(report)
+}
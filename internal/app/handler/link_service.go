@@ -1,233 +1,237 @@
 package handler
 
 import (
-	"fmt"
+	"sirius_future/internal/app/dto"
 	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
 	"sirius_future/internal/app/usecase"
-	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type LinkHandler struct {
 	usecase usecase.FutureSiriusUsecase
+	service service.FutureSiriusService
+	log     service.LoggerService
 }
 
-func NewLinkHandler(usecase usecase.FutureSiriusUsecase) *LinkHandler {
-	return &LinkHandler{usecase: usecase}
+func NewLinkHandler(usecase usecase.FutureSiriusUsecase, service service.FutureSiriusService, log service.LoggerService) *LinkHandler {
+	return &LinkHandler{usecase: usecase, service: service, log: log}
 }
 
-func (lh *LinkHandler) CreateLink(c *fiber.Ctx) error {
-	var request struct {
-		ID    uint `json:"user_id"`
-		Limit uint `json:"link_limit"`
+// bind parses the body into the request DTO and validates it, so invalid
+// requests never reach the usecase
+func (lh *LinkHandler) bind(c *fiber.Ctx, request any) error {
+	if err := c.BodyParser(request); err != nil {
+		return entity.InvalidBody(err)
 	}
-	err := c.BodyParser(&request)
-	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	return lh.service.ValidateRequest(c.UserContext(), request)
+}
+
+func (lh *LinkHandler) CreateLink(c *fiber.Ctx) error {
+	var request dto.CreateLinkRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
 	}
 
-	result, err := lh.usecase.CreateLink(request.ID, request.Limit)
+	result, err := lh.usecase.CreateLink(c.UserContext(), c.Locals("user_id").(uint), request.Limit)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"Link": result,
-	})
+	return c.JSON(dto.CreateLinkResponse{Link: result})
 }
 
 func (lh *LinkHandler) CheckTheLink(c *fiber.Ctx) error {
 	url := c.Params("url")
 
-	result, err := lh.usecase.CheckTheLink(url)
+	result, err := lh.usecase.CheckTheLink(c.UserContext(), url)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"result": result,
-	})
+	return c.JSON(dto.CheckLinkResponse{Result: result})
 }
 
+const registeredResult = "user successfully created, confirm email and phone to activate the account"
+
 func (lh *LinkHandler) CreateUserWithoutLink(c *fiber.Ctx) error {
-	var user *entity.User
-	if err := c.BodyParser(&user); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	var request dto.RegisterRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
 	}
 
-	fmt.Println(user)
+	lh.log.InfoContext(c.UserContext(), "Registering user", "user", request)
 
-	if err := lh.usecase.CreateUser(user); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	user := request.User()
+	if err := lh.usecase.CreateUser(c.UserContext(), user); err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"result": "user successfully created",
+	return c.Status(fiber.StatusCreated).JSON(dto.RegisterResponse{
+		Result: registeredResult,
+		UserID: user.ID,
 	})
 }
 
 func (lh *LinkHandler) CreateUserWithRefferalLink(c *fiber.Ctx) error {
-	var request struct {
-		User entity.User `json:"user"`
-		Url  string      `json:"url"`
-	}
-
-	if err := c.BodyParser(&request); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error":   err.Error(),
-			"message": "error with body",
-		})
+	var request dto.RegisterReferralRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
 	}
 
-	result, err := lh.usecase.CheckTheLink(request.Url)
+	result, err := lh.usecase.CheckTheLink(c.UserContext(), request.Url)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error":   err.Error(),
-			"message": "invalid url ",
-		})
+		return err
 	}
 
 	if !result {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": "invalid link",
-		})
+		return entity.ErrLinkUnavailable
 	}
-	referrer, err := lh.usecase.GetReferrerByUrl(request.Url)
+	referrer, err := lh.usecase.GetReferrerByUrl(c.UserContext(), request.Url)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	request.User.ReferrerID = referrer.ID
+	user := request.User.User()
+	user.ReferrerID = referrer.ID
 
-	if err := lh.usecase.CreateUser(&request.User); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	if err := lh.usecase.CreateUser(c.UserContext(), user); err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"result": "user successfully created",
+	return c.Status(fiber.StatusCreated).JSON(dto.RegisterResponse{
+		Result: registeredResult,
+		UserID: user.ID,
 	})
 }
 
 func (lh *LinkHandler) GetAllUsers(c *fiber.Ctx) error {
-	users, err := lh.usecase.GetAllUsers()
+	page, err := parsePageRequest(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
+	}
+
+	filter := entity.UserFilter{Role: c.Query("role")}
+	if filter.ReferrerID, err = queryUint(c, "referrer_id"); err != nil {
+		return err
 	}
 
-	return c.JSON(users)
+	users, err := lh.usecase.ListUsers(c.UserContext(), filter, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.NewUserPage(users))
 }
 
 func (lh *LinkHandler) GetAllLinks(c *fiber.Ctx) error {
-	links, err := lh.usecase.GetAllLinks()
+	page, err := parsePageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	var filter entity.LinkFilter
+	if filter.ReferrerID, err = queryUint(c, "owner_id"); err != nil {
+		return err
+	}
+	if filter.Status, err = queryBool(c, "status"); err != nil {
+		return err
+	}
+	if filter.Exhausted, err = queryBool(c, "exhausted"); err != nil {
+		return err
+	}
+
+	links, err := lh.usecase.ListLinks(c.UserContext(), filter, page)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(links)
+	return c.JSON(dto.NewLinkPage(links))
 }
 
 func (lh *LinkHandler) GetReferrerByUrl(c *fiber.Ctx) error {
 	url := c.Params("url")
 
-	referrer, err := lh.usecase.GetReferrerByUrl(url)
+	referrer, err := lh.usecase.GetReferrerByUrl(c.UserContext(), url)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(referrer)
+	return c.JSON(dto.NewUserResponse(referrer))
 }
 
 func (lh *LinkHandler) CreatePayment(c *fiber.Ctx) error {
-	var payment *entity.Payment
-	if err := c.BodyParser(&payment); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	var request dto.CreatePaymentRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
 	}
 
-	if err := lh.usecase.CreatePayment(payment); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	if err := lh.usecase.CreatePayment(c.UserContext(), request.Payment()); err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"result": "payment successfully created",
-	})
+	return c.JSON(dto.ResultResponse{Result: "payment successfully created"})
 }
 
 func (lh *LinkHandler) GetAllPayments(c *fiber.Ctx) error {
-	payments, err := lh.usecase.GetAllPayments()
+	page, err := parsePageRequest(c)
+	if err != nil {
+		return err
+	}
+
+	filter, err := parsePaymentFilter(c)
+	if err != nil {
+		return err
+	}
+
+	payments, err := lh.usecase.ListPayments(c.UserContext(), filter, page)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	return c.JSON(payments)
+	return c.JSON(dto.NewPaymentPage(payments))
 }
 
 func (lh *LinkHandler) GetPaymentsByUserID(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := pathID(c)
+	if err != nil {
+		return err
+	}
+
+	page, err := parsePageRequest(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	paymets, err := lh.usecase.GetPaymentsByUserID(uint(id))
+	filter, err := parsePaymentFilter(c)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
+	filter.UserID = &id
 
-	return c.JSON(paymets)
+	paymets, err := lh.usecase.ListPayments(c.UserContext(), filter, page)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.NewPaymentPage(paymets))
 }
 
 func (lh *LinkHandler) UpdatePayment(c *fiber.Ctx) error {
-	id, err := strconv.Atoi(c.Params("id"))
+	id, err := pathID(c)
 	if err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+		return err
 	}
 
-	var payment *entity.Payment
-	if err := c.BodyParser(&payment); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	var request dto.UpdatePaymentRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
 	}
 
-	if err := lh.usecase.UpdatePayment(uint(id), payment); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"Error": err.Error(),
-		})
+	if err := lh.usecase.UpdatePayment(c.UserContext(), id, request.Payment()); err != nil {
+		return err
 	}
 
-	return c.JSON(fiber.Map{
-		"result": "payment successfully updated",
-	})
+	return c.JSON(dto.ResultResponse{Result: "payment successfully updated"})
 }
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"sirius_future/internal/app/entity"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// parsePageRequest reads ?limit=&cursor=&sort=&with_total= of list endpoints
+func parsePageRequest(c *fiber.Ctx) (entity.PageRequest, error) {
+	page := entity.PageRequest{
+		Cursor: c.Query("cursor"),
+		Sort:   c.Query("sort"),
+	}
+
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return page, entity.InvalidField("limit", "must be a positive integer")
+		}
+		page.Limit = limit
+	}
+
+	withTotal, err := queryBool(c, "with_total")
+	if err != nil {
+		return page, err
+	}
+	page.WithTotal = withTotal != nil && *withTotal
+
+	return page, nil
+}
+
+func queryUint(c *fiber.Ctx, name string) (*uint, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return nil, entity.InvalidField(name, "must be a non-negative integer")
+	}
+	result := uint(parsed)
+	return &result, nil
+}
+
+func queryBool(c *fiber.Ctx, name string) (*bool, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, entity.InvalidField(name, "must be true or false")
+	}
+	return &parsed, nil
+}
+
+func queryFloat(c *fiber.Ctx, name string) (*float64, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, entity.InvalidField(name, "must be a number")
+	}
+	return &parsed, nil
+}
+
+// queryTime accepts RFC 3339 timestamps and plain dates (2024-01-31)
+func queryTime(c *fiber.Ctx, name string) (*time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, entity.InvalidField(name, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+}
+
+func parsePaymentFilter(c *fiber.Ctx) (entity.PaymentFilter, error) {
+	filter := entity.PaymentFilter{Status: c.Query("status")}
+
+	var err error
+	if filter.UserID, err = queryUint(c, "user_id"); err != nil {
+		return filter, err
+	}
+	if filter.MinAmount, err = queryFloat(c, "min_amount"); err != nil {
+		return filter, err
+	}
+	if filter.MaxAmount, err = queryFloat(c, "max_amount"); err != nil {
+		return filter, err
+	}
+	if filter.From, err = queryTime(c, "from"); err != nil {
+		return filter, err
+	}
+	if filter.To, err = queryTime(c, "to"); err != nil {
+		return filter, err
+	}
+	return filter, nil
+}
+
+// pathID reads the :id route parameter
+func pathID(c *fiber.Ctx) (uint, error) {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, entity.InvalidField("id", "must be a positive integer")
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"sirius_future/internal/app/dto"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LogHandler struct {
+	levels service.LogLevels
+	log    service.LoggerService
+}
+
+func NewLogHandler(levels service.LogLevels, log service.LoggerService) *LogHandler {
+	return &LogHandler{levels: levels, log: log}
+}
+
+// GetLevels returns the level of every log sink
+func (lgh *LogHandler) GetLevels(c *fiber.Ctx) error {
+	return c.JSON(lgh.levels.Levels())
+}
+
+// SetLevel changes the level of one sink, of all sinks when none is given.
+// The change lasts until restart.
+func (lgh *LogHandler) SetLevel(c *fiber.Ctx) error {
+	var request dto.SetLogLevelRequest
+	if err := lgh.bind(c, &request); err != nil {
+		return err
+	}
+
+	// logged at warn before the change, a higher level would drop the line
+	lgh.log.WarnContext(c.UserContext(), "Changing log level", "sink", request.Sink, "level", request.Level)
+	if err := lgh.levels.SetLevel(request.Sink, request.Level); err != nil {
+		return entity.Validation(err.Error())
+	}
+
+	return c.JSON(lgh.levels.Levels())
+}
+
+// bind parses and validates the request like LinkHandler.bind, the log
+// requests have no database rules
+func (lgh *LogHandler) bind(c *fiber.Ctx, request any) error {
+	if err := c.BodyParser(request); err != nil {
+		return entity.InvalidBody(err)
+	}
+	return entity.ValidateRequest(c.UserContext(), request)
+}
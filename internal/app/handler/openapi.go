@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"sirius_future/internal/app/dto"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/openapi"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	tagHealth   = "health"
+	tagUsers    = "users"
+	tagLinks    = "links"
+	tagAuth     = "auth"
+	tagPayments = "payments"
+	tagAdmin    = "admin"
+)
+
+// Operations documents every route registered in main, the test of the routes
+// fails when a route is missing here
+func Operations() []openapi.Operation {
+	var (
+		badRequest      = http.StatusBadRequest
+		unauthorized    = http.StatusUnauthorized
+		forbidden       = http.StatusForbidden
+		notFound        = http.StatusNotFound
+		conflict        = http.StatusConflict
+		tooManyRequests = http.StatusTooManyRequests
+	)
+
+	return []openapi.Operation{
+		{Method: fiber.MethodGet, Path: "/healthz", Tag: tagHealth, Summary: "Liveness probe, dependencies are not checked",
+			Response: struct {
+				Status string `json:"status"`
+			}{}},
+		{Method: fiber.MethodGet, Path: "/readyz", Tag: tagHealth, Summary: "Readiness probe, 503 when a critical dependency is down",
+			Response: service.HealthReport{}, Other: map[int]any{http.StatusServiceUnavailable: service.HealthReport{}}},
+		{Method: fiber.MethodGet, Path: "/metrics", Tag: tagHealth, Summary: "Prometheus metrics",
+			Response: "", ContentType: fiber.MIMETextPlain},
+
+		{Method: fiber.MethodGet, Path: "/api/check-link/:url", Tag: tagLinks, Summary: "Check that a referral link can be used",
+			Response: dto.CheckLinkResponse{}},
+		{Method: fiber.MethodPost, Path: "/register", Tag: tagUsers, Summary: "Register a user",
+			Request: dto.RegisterRequest{}, Status: http.StatusCreated, Response: dto.RegisterResponse{},
+			Errors: []int{badRequest, conflict}},
+		{Method: fiber.MethodPost, Path: "/register/referral", Tag: tagUsers, Summary: "Register a user with a referral link",
+			Request: dto.RegisterReferralRequest{}, Status: http.StatusCreated, Response: dto.RegisterResponse{},
+			Errors: []int{badRequest, notFound, conflict}},
+
+		{Method: fiber.MethodPost, Path: "/verify/email", Tag: tagUsers, Summary: "Confirm the email with the emailed token",
+			Request: dto.VerifyEmailRequest{}, Response: dto.VerificationResponse{}, Errors: []int{badRequest, conflict}},
+		{Method: fiber.MethodPost, Path: "/verify/phone", Tag: tagUsers, Summary: "Confirm the phone with the SMS code",
+			Request: dto.VerifyPhoneRequest{}, Response: dto.VerificationResponse{},
+			Errors: []int{badRequest, notFound, conflict, tooManyRequests}},
+		{Method: fiber.MethodPost, Path: "/verify/resend", Tag: tagUsers, Summary: "Send new verification codes",
+			Request: dto.ResendVerificationRequest{}, Response: dto.ResultResponse{},
+			Errors: []int{badRequest, notFound, conflict, tooManyRequests}},
+
+		{Method: fiber.MethodPost, Path: "/auth/login", Tag: tagAuth, Summary: "Log in, returns the token of the second step when 2FA is enabled",
+			Request: dto.LoginRequest{}, Response: dto.LoginResponse{},
+			Errors: []int{badRequest, unauthorized, forbidden, tooManyRequests}},
+		{Method: fiber.MethodPost, Path: "/auth/forgot-password", Tag: tagAuth, Summary: "Send a password reset code",
+			Request: dto.ForgotPasswordRequest{}, Response: dto.ResultResponse{}, Errors: []int{badRequest, tooManyRequests}},
+		{Method: fiber.MethodPost, Path: "/auth/reset-password", Tag: tagAuth, Summary: "Set a new password with the reset code",
+			Request: dto.ResetPasswordRequest{}, Response: dto.ResultResponse{}, Errors: []int{badRequest}},
+		{Method: fiber.MethodPost, Path: "/auth/login/2fa", Tag: tagAuth, Summary: "Second login step with a TOTP or recovery code",
+			Request: dto.LoginMFARequest{}, Response: dto.TokenResponse{},
+			Errors: []int{badRequest, unauthorized, tooManyRequests}},
+		{Method: fiber.MethodPost, Path: "/auth/2fa/enroll", Tag: tagAuth, Summary: "Start two-factor authentication enrollment",
+			Auth: true, Response: dto.TOTPEnrollmentResponse{}, Errors: []int{unauthorized, conflict}},
+		{Method: fiber.MethodPost, Path: "/auth/2fa/confirm", Tag: tagAuth, Summary: "Enable two-factor authentication",
+			Auth: true, Request: dto.ConfirmTOTPRequest{}, Response: dto.ConfirmTOTPResponse{},
+			Errors: []int{badRequest, unauthorized, conflict}},
+
+		{Method: fiber.MethodGet, Path: "/api/users", Tag: tagUsers, Summary: "List users",
+			Auth: true, Query: append(pageQuery("id, created_at, email, last_name"),
+				openapi.Query("role", "string", "only users with the role"),
+				openapi.Query("referrer_id", "integer", "only users referred by the user")),
+			Response: entity.Page[dto.UserResponse]{}, Errors: []int{badRequest, unauthorized}},
+		{Method: fiber.MethodPost, Path: "/api/create-link", Tag: tagLinks, Summary: "Create a referral link of the authenticated user",
+			Auth: true, Request: dto.CreateLinkRequest{}, Response: dto.CreateLinkResponse{}, Errors: []int{badRequest, unauthorized, forbidden}},
+		{Method: fiber.MethodGet, Path: "/api/links", Tag: tagLinks, Summary: "List referral links",
+			Auth: true, Query: append(pageQuery("id, created_at, count, limit"),
+				openapi.Query("owner_id", "integer", "only links of the user"),
+				openapi.Query("status", "boolean", "only enabled or disabled links"),
+				openapi.Query("exhausted", "boolean", "only links that reached or didn't reach the limit")),
+			Response: entity.Page[dto.LinkResponse]{}, Errors: []int{badRequest, unauthorized}},
+		{Method: fiber.MethodGet, Path: "/api/get-referrer/:url", Tag: tagLinks, Summary: "Get the owner of a referral link",
+			Auth: true, Response: dto.UserResponse{}, Errors: []int{unauthorized, notFound}},
+
+		{Method: fiber.MethodPost, Path: "/api/payments", Tag: tagPayments, Summary: "Create a payment",
+			Auth: true, Request: dto.CreatePaymentRequest{}, Response: dto.ResultResponse{},
+			Errors: []int{badRequest, unauthorized}},
+		{Method: fiber.MethodGet, Path: "/api/payments", Tag: tagPayments, Summary: "List payments",
+			Auth: true, Query: append(paymentQuery(), openapi.Query("user_id", "integer", "only payments of the user")),
+			Response: entity.Page[dto.PaymentResponse]{}, Errors: []int{badRequest, unauthorized}},
+		{Method: fiber.MethodGet, Path: "/api/payments/user/:id", Tag: tagPayments, Summary: "List payments of a user",
+			Auth: true, Query: paymentQuery(),
+			Response: entity.Page[dto.PaymentResponse]{}, Errors: []int{badRequest, unauthorized}},
+		{Method: fiber.MethodPatch, Path: "/api/payments/:id", Tag: tagPayments, Summary: "Update a payment, admin and finance only, requires 2FA",
+			Auth: true, Request: dto.UpdatePaymentRequest{}, Response: dto.ResultResponse{},
+			Errors: []int{badRequest, unauthorized, forbidden, notFound}},
+
+		{Method: fiber.MethodPost, Path: "/api/admin/unlock", Tag: tagAdmin, Summary: "Clear the login lockout of an account or an ip",
+			Auth: true, Request: dto.UnlockLoginRequest{}, Response: dto.ResultResponse{},
+			Errors: []int{badRequest, unauthorized, forbidden}},
+		{Method: fiber.MethodGet, Path: "/api/admin/log-level", Tag: tagAdmin, Summary: "Get the level of every log sink",
+			Auth: true, Response: map[string]string{}, Errors: []int{unauthorized, forbidden}},
+		{Method: fiber.MethodPut, Path: "/api/admin/log-level", Tag: tagAdmin, Summary: "Change the log level until restart",
+			Auth: true, Request: dto.SetLogLevelRequest{}, Response: map[string]string{},
+			Errors: []int{badRequest, unauthorized, forbidden}},
+	}
+}
+
+// pageQuery documents the cursor pagination of the list endpoints
+func pageQuery(sortFields string) []openapi.Parameter {
+	return []openapi.Parameter{
+		openapi.Query("limit", "integer", fmt.Sprintf("page size, %d by default, at most %d", entity.DefaultPageLimit, entity.MaxPageLimit)),
+		openapi.Query("cursor", "string", "next_cursor of the previous page, used with the same sort and filters"),
+		openapi.Query("sort", "string", "one of "+sortFields+", a \"-\" prefix sorts descending"),
+		openapi.Query("with_total", "boolean", "count the matching rows"),
+	}
+}
+
+func paymentQuery() []openapi.Parameter {
+	return append(pageQuery("id, created_at, amount"),
+		openapi.Query("status", "string", "only payments with the status"),
+		openapi.Query("min_amount", "number", ""),
+		openapi.Query("max_amount", "number", ""),
+		openapi.Query("from", "date-time", "created at or after, RFC 3339 or YYYY-MM-DD"),
+		openapi.Query("to", "date-time", "created before, RFC 3339 or YYYY-MM-DD"),
+	)
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"sirius_future/internal/app/dto"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func (lh *LinkHandler) VerifyEmail(c *fiber.Ctx) error {
+	var request dto.VerifyEmailRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	user, err := lh.usecase.VerifyEmail(c.UserContext(), request.Token)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.VerificationResponse{Result: "email successfully confirmed", Status: user.Status})
+}
+
+func (lh *LinkHandler) VerifyPhone(c *fiber.Ctx) error {
+	var request dto.VerifyPhoneRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	user, err := lh.usecase.VerifyPhone(c.UserContext(), request.UserID, request.Code)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(dto.VerificationResponse{Result: "phone successfully confirmed", Status: user.Status})
+}
+
+func (lh *LinkHandler) ResendVerification(c *fiber.Ctx) error {
+	var request dto.ResendVerificationRequest
+	if err := lh.bind(c, &request); err != nil {
+		return err
+	}
+
+	if err := lh.usecase.ResendVerification(c.UserContext(), request.UserID, c.IP()); err != nil {
+		return err
+	}
+
+	return c.JSON(dto.ResultResponse{Result: "verification codes sent"})
+}
@@ -0,0 +1,332 @@
+package migration
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migrations are embedded per driver as <version>_<name>.up.sql and
+// <version>_<name>.down.sql. Statements end with ";" at the end of a line.
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var files embed.FS
+
+var (
+	ErrSchemaAhead       = errors.New("database schema is ahead of the binary")
+	ErrPendingMigrations = errors.New("database has pending migrations")
+	ErrUnknownVersion    = errors.New("unknown migration version")
+)
+
+// advisoryLockID serializes migrations of several instances on postgres
+const advisoryLockID = 7346512389
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration known to the binary or recorded in the database.
+// A migration applied by a newer binary has no Name.
+type Status struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+}
+
+type appliedMigration struct {
+	Version   uint
+	Name      string
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type Migrator struct {
+	db         *gorm.DB
+	driver     string
+	migrations []Migration // sorted by version
+}
+
+func NewMigrator(db *gorm.DB, driver string) (*Migrator, error) {
+	migrations, err := load(driver)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: migrations}, nil
+}
+
+func load(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(name, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		number, title, ok := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(number, 10, 32)
+		if !ok || err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+
+		data, err := files.ReadFile(path.Join(driver, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: title}
+			byVersion[uint(version)] = m
+		}
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d must have both up and down files", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest is the version the binary expects
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Current is the highest applied version, zero for an empty database
+func (m *Migrator) Current() (uint, error) {
+	if err := m.ensureTable(m.db); err != nil {
+		return 0, err
+	}
+	return currentVersion(m.db)
+}
+
+// Check is the startup check: the schema must not be ahead of the binary,
+// pending migrations are reported with ErrPendingMigrations
+func (m *Migrator) Check() error {
+	current, err := m.Current()
+	if err != nil {
+		return err
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("%w: schema version %d, binary supports up to %d", ErrSchemaAhead, current, m.Latest())
+	}
+	if current < m.Latest() {
+		return fmt.Errorf("%w: schema version %d, binary expects %d", ErrPendingMigrations, current, m.Latest())
+	}
+	return nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() ([]Migration, error) {
+	return m.To(m.Latest())
+}
+
+// Down reverts the last applied migration
+func (m *Migrator) Down() ([]Migration, error) {
+	current, err := m.Current()
+	if err != nil {
+		return nil, err
+	}
+	if current == 0 {
+		return nil, nil
+	}
+
+	var target uint
+	for _, migration := range m.migrations {
+		if migration.Version < current {
+			target = migration.Version
+		}
+	}
+	return m.To(target)
+}
+
+// To migrates up or down to the version, zero reverts everything. Every
+// migration runs in its own transaction together with its schema_migrations
+// row, so a failed migration leaves the schema at the previous version.
+func (m *Migrator) To(version uint) ([]Migration, error) {
+	if version != 0 && m.find(version) == nil {
+		return nil, fmt.Errorf("%w %d", ErrUnknownVersion, version)
+	}
+	if err := m.ensureTable(m.db); err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for {
+		var step *Migration
+		err := m.db.Transaction(func(tx *gorm.DB) error {
+			if err := m.lock(tx); err != nil {
+				return err
+			}
+
+			// the version is read under the lock, another instance may have migrated meanwhile
+			current, err := currentVersion(tx)
+			if err != nil {
+				return err
+			}
+			if current > m.Latest() {
+				return fmt.Errorf("%w: schema version %d, binary supports up to %d", ErrSchemaAhead, current, m.Latest())
+			}
+
+			switch {
+			case current < version:
+				step = m.next(current)
+				if err := execute(tx, step.Up); err != nil {
+					return fmt.Errorf("migration %d_%s up: %w", step.Version, step.Name, err)
+				}
+				return tx.Create(&appliedMigration{Version: step.Version, Name: step.Name, AppliedAt: time.Now().UTC()}).Error
+			case current > version:
+				step = m.find(current)
+				if step == nil {
+					return fmt.Errorf("%w %d", ErrUnknownVersion, current)
+				}
+				if err := execute(tx, step.Down); err != nil {
+					return fmt.Errorf("migration %d_%s down: %w", step.Version, step.Name, err)
+				}
+				return tx.Where("version = ?", step.Version).Delete(&appliedMigration{}).Error
+			default:
+				step = nil
+				return nil
+			}
+		})
+		if err != nil {
+			return done, err
+		}
+		if step == nil {
+			return done, nil
+		}
+		done = append(done, *step)
+	}
+}
+
+// Status lists the migrations of the binary and those recorded in the database
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.ensureTable(m.db); err != nil {
+		return nil, err
+	}
+
+	var applied []appliedMigration
+	if err := m.db.Order("version").Find(&applied).Error; err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Status{}
+	for _, migration := range m.migrations {
+		byVersion[migration.Version] = &Status{Version: migration.Version, Name: migration.Name}
+	}
+	for _, a := range applied {
+		appliedAt := a.AppliedAt
+		if status, ok := byVersion[a.Version]; ok {
+			status.AppliedAt = &appliedAt
+		} else {
+			byVersion[a.Version] = &Status{Version: a.Version, AppliedAt: &appliedAt}
+		}
+	}
+
+	statuses := make([]Status, 0, len(byVersion))
+	for _, status := range byVersion {
+		statuses = append(statuses, *status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY, name text NOT NULL, applied_at timestamp NOT NULL)`).Error
+}
+
+func (m *Migrator) lock(tx *gorm.DB) error {
+	if m.driver != "postgres" {
+		// sqlite serializes write transactions itself
+		return nil
+	}
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", advisoryLockID).Error
+}
+
+func (m *Migrator) find(version uint) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// next returns the first migration after the version
+func (m *Migrator) next(version uint) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version > version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+func currentVersion(db *gorm.DB) (uint, error) {
+	var version uint
+	err := db.Model(&appliedMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// execute runs the statements of a migration file one by one, drivers differ
+// in their support of several statements in one call
+func execute(tx *gorm.DB, script string) error {
+	for _, statement := range statements(script) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func statements(script string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			result = append(result, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		result = append(result, rest)
+	}
+	return result
+}
@@ -0,0 +1,245 @@
+package migration
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "one per line",
+			script: "CREATE TABLE a (id integer);\nDROP TABLE b;\n",
+			want:   []string{"CREATE TABLE a (id integer);", "DROP TABLE b;"},
+		},
+		{
+			name:   "comments and blank lines",
+			script: "-- a comment;\n\n  -- indented comment\nDROP TABLE a;\n\n",
+			want:   []string{"DROP TABLE a;"},
+		},
+		{
+			name:   "statement over several lines",
+			script: "UPDATE a SET b = CASE\n\tWHEN c THEN 1\n\tELSE 2\nEND\nWHERE d;\nDROP TABLE e;",
+			want:   []string{"UPDATE a SET b = CASE\n\tWHEN c THEN 1\n\tELSE 2\nEND\nWHERE d;", "DROP TABLE e;"},
+		},
+		{
+			name:   "semicolon inside a line",
+			script: "INSERT INTO a VALUES (';', 1);\n",
+			want:   []string{"INSERT INTO a VALUES (';', 1);"},
+		},
+		{
+			name:   "last statement without semicolon",
+			script: "DROP TABLE a;\nDROP TABLE b",
+			want:   []string{"DROP TABLE a;", "DROP TABLE b"},
+		},
+		{
+			name:   "empty",
+			script: "-- nothing to do\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("statements() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Both drivers must have the same migrations, the schema version means the
+// same on either database
+func TestDriversHaveTheSameMigrations(t *testing.T) {
+	sqliteMigrations, err := load("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgresMigrations, err := load("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := func(migrations []Migration) []string {
+		var result []string
+		for _, m := range migrations {
+			result = append(result, m.Name)
+		}
+		return result
+	}
+	if !reflect.DeepEqual(names(sqliteMigrations), names(postgresMigrations)) {
+		t.Errorf("sqlite migrations %v, postgres migrations %v", names(sqliteMigrations), names(postgresMigrations))
+	}
+	for i, m := range sqliteMigrations {
+		if m.Version != uint(i+1) {
+			t.Errorf("migration %s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+	}
+}
+
+func newTestMigrator(t *testing.T) (*Migrator, *gorm.DB) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := NewMigrator(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return migrator, db
+}
+
+// A database created by AutoMigrate is migrated up, reverted step by step
+// and migrated up again
+func TestUpDownUp(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+
+	if _, err := migrator.To(1); err != nil {
+		t.Fatal(err)
+	}
+	// user 1 has a plain text password of a legacy registration, user 3 registered after hashing
+	const passwordHash = "$2a$10$b2b4DiMGvbPKO6VuJ1bf..RjkLsGXMFchKjlYeoKT.Udkd4wr0g/S"
+	legacy := []string{
+		"INSERT INTO users (id, created_at, firstname, secondname, lastname, email, password, phone, role) VALUES (1, '2023-05-01 10:00:00+00:00', 'Ivan', 'Ivanovich', 'Ivanov', ' Ivan@Mail.RU ', 'plain', '8 (999) 123-45-67', 'parent')",
+		"INSERT INTO users (id, created_at, firstname, secondname, lastname, email, password, phone, role) VALUES (2, '2023-05-02 10:00:00+00:00', 'Ivan', 'Ivanovich', 'Ivanov', 'ivan@mail.ru', NULL, '9991112233', 'parent')",
+		"INSERT INTO users (id, created_at, firstname, secondname, lastname, email, password, phone, role) VALUES (3, '2023-05-03 10:00:00+00:00', 'Petr', 'Petrovich', 'Petrov', 'petr@mail.ru', '" + passwordHash + "', '+7 999 111-22-33', 'student')",
+		"INSERT INTO payments (id, user_id, amount, description, status) VALUES (1, 3, 100, 'lesson', '')",
+	}
+	for _, statement := range legacy {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	applied, err := migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != int(migrator.Latest())-1 {
+		t.Errorf("applied %d migrations, want %d", len(applied), migrator.Latest()-1)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Fatalf("Check() after Up() = %v", err)
+	}
+
+	type user struct {
+		ID        uint
+		Email     string
+		Phone     string
+		Password  string
+		Status    string
+		DeletedAt *string
+	}
+	var users []user
+	if err := db.Raw("SELECT id, email, phone, password, status, deleted_at FROM users ORDER BY id").Scan(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("got %d users, want 3", len(users))
+	}
+	if users[0].Email != "ivan@mail.ru" || users[0].Phone != "+79991234567" || users[0].Status != "active" || users[0].DeletedAt != nil {
+		t.Errorf("user 1 is not normalized: %+v", users[0])
+	}
+	if users[0].Password != "" || users[1].Password != "" || users[2].Password != passwordHash {
+		t.Errorf("plain text passwords are not cleared or hashes are lost: %+v", users)
+	}
+	if users[1].DeletedAt == nil {
+		t.Errorf("user 2 shares the email of user 1 and is not soft deleted: %+v", users[1])
+	}
+	if users[2].Phone != "+79991112233" || users[2].DeletedAt != nil {
+		t.Errorf("user 3 keeps its phone, the duplicate of user 2 is deleted: %+v", users[2])
+	}
+
+	var payment struct {
+		CreatedAt time.Time
+		Currency  string
+		Status    string
+	}
+	if err := db.Raw("SELECT created_at, currency, status FROM payments WHERE id = 1").Scan(&payment).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !payment.CreatedAt.Equal(time.Date(2023, 5, 3, 10, 0, 0, 0, time.UTC)) || payment.Currency != "RUB" || payment.Status != "pending" {
+		t.Errorf("payment is not backfilled: %+v", payment)
+	}
+
+	for current := migrator.Latest(); current > 0; current-- {
+		reverted, err := migrator.Down()
+		if err != nil {
+			t.Fatalf("Down() from %d = %v", current, err)
+		}
+		if len(reverted) != 1 || reverted[0].Version != current {
+			t.Fatalf("Down() from %d reverted %v", current, reverted)
+		}
+	}
+	if version, err := migrator.Current(); err != nil || version != 0 {
+		t.Fatalf("Current() after reverting everything = %d, %v", version, err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Error("users table is left after reverting everything")
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		t.Fatalf("Up() after Down() = %v", err)
+	}
+	if _, err := migrator.To(3); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := migrator.Current(); err != nil || version != 3 {
+		t.Fatalf("Current() after To(3) = %d, %v", version, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	migrator, db := newTestMigrator(t)
+
+	if err := migrator.Check(); !errors.Is(err, ErrPendingMigrations) {
+		t.Errorf("Check() of an empty database = %v, want %v", err, ErrPendingMigrations)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	// a newer binary migrated the database
+	ahead := appliedMigration{Version: migrator.Latest() + 1, Name: "from_the_future"}
+	if err := db.Create(&ahead).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Check(); !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("Check() of a database ahead = %v, want %v", err, ErrSchemaAhead)
+	}
+	if _, err := migrator.Up(); !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("Up() of a database ahead = %v, want %v", err, ErrSchemaAhead)
+	}
+	if _, err := migrator.Down(); !errors.Is(err, ErrSchemaAhead) {
+		t.Errorf("Down() of a database ahead = %v, want %v", err, ErrSchemaAhead)
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := statuses[len(statuses)-1]
+	if last.Version != ahead.Version || last.Name != "" || last.AppliedAt == nil {
+		t.Errorf("Status() reports the unknown migration as %+v", last)
+	}
+}
+
+func TestToUnknownVersion(t *testing.T) {
+	migrator, _ := newTestMigrator(t)
+
+	if _, err := migrator.To(migrator.Latest() + 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("To() of an unknown version = %v, want %v", err, ErrUnknownVersion)
+	}
+}
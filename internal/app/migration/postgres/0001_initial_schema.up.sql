@@ -0,0 +1,9 @@
+-- Schema created by AutoMigrate before versioned migrations were introduced.
+-- IF NOT EXISTS lets databases created back then adopt the migrations.
+CREATE TABLE IF NOT EXISTS "links" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"url" text NOT NULL,"referrer_id" bigint NOT NULL,"count" bigint,"status" boolean DEFAULT true,"limit" bigint,CONSTRAINT "uni_links_url" UNIQUE ("url"));
+CREATE INDEX IF NOT EXISTS "idx_links_deleted_at" ON "links" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "users" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"firstname" text NOT NULL,"secondname" text NOT NULL,"lastname" text NOT NULL,"email" text NOT NULL,"password" text,"phone" text NOT NULL,"role" text NOT NULL,"referrer_id" bigint);
+CREATE INDEX IF NOT EXISTS "idx_users_deleted_at" ON "users" ("deleted_at");
+
+CREATE TABLE IF NOT EXISTS "payments" ("id" bigserial PRIMARY KEY,"user_id" bigint NOT NULL,"amount" decimal NOT NULL,"description" text NOT NULL,"status" text NOT NULL,CONSTRAINT "fk_payments_user" FOREIGN KEY ("user_id") REFERENCES "users"("id"));
@@ -0,0 +1,26 @@
+-- Emails and phones are normalized the way entity.NormalizeEmail and
+-- entity.NormalizePhone do before the unique indexes are created: emails are
+-- trimmed and lower-cased, phones keep their digits only and get the E.164
+-- "+" prefix, "00" and the local "8XXXXXXXXXX" and "9XXXXXXXXX" forms become
+-- international.
+UPDATE "users" SET "email" = lower(btrim("email"));
+UPDATE "users" SET "phone" = CASE
+		WHEN btrim("normalized"."phone") = '' THEN ''
+		WHEN btrim("normalized"."phone") LIKE '+%' THEN '+' || "normalized"."digits"
+		WHEN "normalized"."digits" LIKE '00%' THEN '+' || substr("normalized"."digits", 3)
+		WHEN "normalized"."digits" ~ '^8[0-9]{10}$' THEN '+7' || substr("normalized"."digits", 2)
+		WHEN "normalized"."digits" ~ '^9[0-9]{9}$' THEN '+7' || "normalized"."digits"
+		ELSE '+' || "normalized"."digits"
+	END
+FROM (SELECT "id", "phone", regexp_replace("phone", '[^0-9]', '', 'g') AS "digits" FROM "users") AS "normalized"
+WHERE "normalized"."id" = "users"."id";
+-- Accounts sharing an email or a phone after normalization: the oldest one
+-- (lowest id) keeps it, the later ones are soft deleted so they can't log in
+-- but stay in the table for a manual merge. Emails are deduplicated first,
+-- phones among the remaining accounts.
+UPDATE "users" SET "deleted_at" = now()
+WHERE "deleted_at" IS NULL AND EXISTS (SELECT 1 FROM "users" AS "kept" WHERE "kept"."email" = "users"."email" AND "kept"."deleted_at" IS NULL AND "kept"."id" < "users"."id");
+UPDATE "users" SET "deleted_at" = now()
+WHERE "deleted_at" IS NULL AND EXISTS (SELECT 1 FROM "users" AS "kept" WHERE "kept"."phone" = "users"."phone" AND "kept"."deleted_at" IS NULL AND "kept"."id" < "users"."id");
+CREATE UNIQUE INDEX "idx_users_email" ON "users" ("email") WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX "idx_users_phone" ON "users" ("phone") WHERE deleted_at IS NULL;
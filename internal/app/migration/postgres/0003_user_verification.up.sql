@@ -0,0 +1,15 @@
+-- Users registered before verification existed are backfilled as active so they keep access.
+ALTER TABLE "users" ADD COLUMN "status" text NOT NULL DEFAULT 'pending';
+UPDATE "users" SET "status" = 'active';
+ALTER TABLE "users" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "users" ADD COLUMN "phone_verified_at" timestamptz;
+
+CREATE TABLE "verifications" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" bigint NOT NULL,"channel" text NOT NULL,"code_hash" text NOT NULL,"attempts" bigint NOT NULL DEFAULT 0,"expires_at" timestamptz NOT NULL,"used_at" timestamptz);
+CREATE INDEX "idx_verifications_deleted_at" ON "verifications" ("deleted_at");
+CREATE INDEX "idx_verifications_user_id" ON "verifications" ("user_id");
+CREATE INDEX "idx_verifications_code_hash" ON "verifications" ("code_hash");
+
+CREATE TABLE "referral_rewards" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"referrer_id" bigint NOT NULL,"referred_id" bigint NOT NULL);
+CREATE INDEX "idx_referral_rewards_deleted_at" ON "referral_rewards" ("deleted_at");
+CREATE INDEX "idx_referral_rewards_referrer_id" ON "referral_rewards" ("referrer_id");
+CREATE UNIQUE INDEX "idx_referral_rewards_referred_id" ON "referral_rewards" ("referred_id");
@@ -0,0 +1,6 @@
+-- Passwords of users registered before hashing are stored in plain text.
+-- They can't be hashed here and bcrypt never matches them, so they are cleared:
+-- such accounts log in again after resetting the password with /auth/forgot-password.
+UPDATE "users" SET "password" = '' WHERE "password" IS NULL OR NOT (length("password") = 60 AND "password" LIKE '$2_$%');
+ALTER TABLE "users" ALTER COLUMN "password" SET NOT NULL;
+ALTER TABLE "users" ADD COLUMN "token_version" bigint NOT NULL DEFAULT 0;
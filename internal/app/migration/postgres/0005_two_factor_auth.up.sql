@@ -0,0 +1,7 @@
+ALTER TABLE "users" ADD COLUMN "totp_secret" text;
+ALTER TABLE "users" ADD COLUMN "totp_enabled" boolean NOT NULL DEFAULT false;
+ALTER TABLE "users" ADD COLUMN "totp_last_step" bigint;
+
+CREATE TABLE "recovery_codes" ("id" bigserial PRIMARY KEY,"created_at" timestamptz,"updated_at" timestamptz,"deleted_at" timestamptz,"user_id" bigint NOT NULL,"code_hash" text NOT NULL,"used_at" timestamptz);
+CREATE INDEX "idx_recovery_codes_deleted_at" ON "recovery_codes" ("deleted_at");
+CREATE INDEX "idx_recovery_codes_user_id" ON "recovery_codes" ("user_id");
@@ -0,0 +1,9 @@
+-- Payments made before timestamps were tracked are dated with the registration
+-- of their user. created_at is NOT NULL, rows without it would never match the
+-- keyset predicate of the list pagination.
+ALTER TABLE "payments" ADD COLUMN "created_at" timestamptz;
+ALTER TABLE "payments" ADD COLUMN "updated_at" timestamptz;
+UPDATE "payments" SET "created_at" = COALESCE((SELECT "created_at" FROM "users" WHERE "users"."id" = "payments"."user_id"), now());
+UPDATE "payments" SET "updated_at" = "created_at";
+ALTER TABLE "payments" ALTER COLUMN "created_at" SET NOT NULL;
+CREATE INDEX "idx_payments_created_at" ON "payments" ("created_at");
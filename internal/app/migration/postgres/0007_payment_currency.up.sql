@@ -0,0 +1,4 @@
+-- Payments made before currencies were tracked are in roubles.
+-- Free text statuses of older payments are kept, empty ones become pending.
+ALTER TABLE "payments" ADD COLUMN "currency" text NOT NULL DEFAULT 'RUB';
+UPDATE "payments" SET "status" = 'pending' WHERE "status" = '';
@@ -0,0 +1,9 @@
+-- Schema created by AutoMigrate before versioned migrations were introduced.
+-- IF NOT EXISTS lets databases created back then adopt the migrations.
+CREATE TABLE IF NOT EXISTS `links` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`url` text NOT NULL,`referrer_id` integer NOT NULL,`count` integer,`status` numeric DEFAULT true,`limit` integer,CONSTRAINT `uni_links_url` UNIQUE (`url`));
+CREATE INDEX IF NOT EXISTS `idx_links_deleted_at` ON `links`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `users` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`firstname` text NOT NULL,`secondname` text NOT NULL,`lastname` text NOT NULL,`email` text NOT NULL,`password` text,`phone` text NOT NULL,`role` text NOT NULL,`referrer_id` integer);
+CREATE INDEX IF NOT EXISTS `idx_users_deleted_at` ON `users`(`deleted_at`);
+
+CREATE TABLE IF NOT EXISTS `payments` (`id` integer PRIMARY KEY AUTOINCREMENT,`user_id` integer NOT NULL,`amount` real NOT NULL,`description` text NOT NULL,`status` text NOT NULL,CONSTRAINT `fk_payments_user` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`));
@@ -0,0 +1,3 @@
+-- Normalized contacts and soft deleted duplicates are kept.
+DROP INDEX IF EXISTS `idx_users_phone`;
+DROP INDEX IF EXISTS `idx_users_email`;
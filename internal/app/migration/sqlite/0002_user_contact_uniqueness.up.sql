@@ -0,0 +1,26 @@
+-- Emails and phones are normalized the way entity.NormalizeEmail and
+-- entity.NormalizePhone do before the unique indexes are created: emails are
+-- trimmed and lower-cased, phones lose spaces, dashes, dots and brackets and
+-- get the E.164 "+" prefix, "00" and the local "8XXXXXXXXXX" and "9XXXXXXXXX"
+-- forms become international.
+UPDATE `users` SET `email` = lower(trim(`email`));
+UPDATE `users` SET `phone` = CASE
+		WHEN trim(`normalized`.`phone`) = '' THEN ''
+		WHEN trim(`normalized`.`phone`) LIKE '+%' THEN '+' || `normalized`.`digits`
+		WHEN `normalized`.`digits` LIKE '00%' THEN '+' || substr(`normalized`.`digits`, 3)
+		WHEN length(`normalized`.`digits`) = 11 AND `normalized`.`digits` LIKE '8%' THEN '+7' || substr(`normalized`.`digits`, 2)
+		WHEN length(`normalized`.`digits`) = 10 AND `normalized`.`digits` LIKE '9%' THEN '+7' || `normalized`.`digits`
+		ELSE '+' || `normalized`.`digits`
+	END
+FROM (SELECT `id`, `phone`, replace(replace(replace(replace(replace(replace(trim(`phone`),' ',''),'-',''),'.',''),'(',''),')',''),'+','') AS `digits` FROM `users`) AS `normalized`
+WHERE `normalized`.`id` = `users`.`id`;
+-- Accounts sharing an email or a phone after normalization: the oldest one
+-- (lowest id) keeps it, the later ones are soft deleted so they can't log in
+-- but stay in the table for a manual merge. Emails are deduplicated first,
+-- phones among the remaining accounts.
+UPDATE `users` SET `deleted_at` = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE `deleted_at` IS NULL AND EXISTS (SELECT 1 FROM `users` AS `kept` WHERE `kept`.`email` = `users`.`email` AND `kept`.`deleted_at` IS NULL AND `kept`.`id` < `users`.`id`);
+UPDATE `users` SET `deleted_at` = strftime('%Y-%m-%d %H:%M:%f+00:00', 'now')
+WHERE `deleted_at` IS NULL AND EXISTS (SELECT 1 FROM `users` AS `kept` WHERE `kept`.`phone` = `users`.`phone` AND `kept`.`deleted_at` IS NULL AND `kept`.`id` < `users`.`id`);
+CREATE UNIQUE INDEX `idx_users_email` ON `users`(`email`) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX `idx_users_phone` ON `users`(`phone`) WHERE deleted_at IS NULL;
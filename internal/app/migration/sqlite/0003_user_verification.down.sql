@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS `referral_rewards`;
+DROP TABLE IF EXISTS `verifications`;
+
+ALTER TABLE `users` DROP COLUMN `phone_verified_at`;
+ALTER TABLE `users` DROP COLUMN `email_verified_at`;
+ALTER TABLE `users` DROP COLUMN `status`;
@@ -0,0 +1,15 @@
+-- Users registered before verification existed are backfilled as active so they keep access.
+ALTER TABLE `users` ADD COLUMN `status` text NOT NULL DEFAULT "pending";
+UPDATE `users` SET `status` = 'active';
+ALTER TABLE `users` ADD COLUMN `email_verified_at` datetime;
+ALTER TABLE `users` ADD COLUMN `phone_verified_at` datetime;
+
+CREATE TABLE `verifications` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`user_id` integer NOT NULL,`channel` text NOT NULL,`code_hash` text NOT NULL,`attempts` integer NOT NULL DEFAULT 0,`expires_at` datetime NOT NULL,`used_at` datetime);
+CREATE INDEX `idx_verifications_deleted_at` ON `verifications`(`deleted_at`);
+CREATE INDEX `idx_verifications_user_id` ON `verifications`(`user_id`);
+CREATE INDEX `idx_verifications_code_hash` ON `verifications`(`code_hash`);
+
+CREATE TABLE `referral_rewards` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`referrer_id` integer NOT NULL,`referred_id` integer NOT NULL);
+CREATE INDEX `idx_referral_rewards_deleted_at` ON `referral_rewards`(`deleted_at`);
+CREATE INDEX `idx_referral_rewards_referrer_id` ON `referral_rewards`(`referrer_id`);
+CREATE UNIQUE INDEX `idx_referral_rewards_referred_id` ON `referral_rewards`(`referred_id`);
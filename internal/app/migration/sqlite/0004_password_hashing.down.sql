@@ -0,0 +1,8 @@
+CREATE TABLE `users_old` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`firstname` text NOT NULL,`secondname` text NOT NULL,`lastname` text NOT NULL,`email` text NOT NULL,`password` text,`phone` text NOT NULL,`role` text NOT NULL,`referrer_id` integer,`status` text NOT NULL DEFAULT "pending",`email_verified_at` datetime,`phone_verified_at` datetime);
+INSERT INTO `users_old` (`id`,`created_at`,`updated_at`,`deleted_at`,`firstname`,`secondname`,`lastname`,`email`,`password`,`phone`,`role`,`referrer_id`,`status`,`email_verified_at`,`phone_verified_at`)
+SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`firstname`,`secondname`,`lastname`,`email`,`password`,`phone`,`role`,`referrer_id`,`status`,`email_verified_at`,`phone_verified_at` FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_old` RENAME TO `users`;
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_users_email` ON `users`(`email`) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX `idx_users_phone` ON `users`(`phone`) WHERE deleted_at IS NULL;
@@ -0,0 +1,14 @@
+-- sqlite can't alter column constraints, the users table is rebuilt to make
+-- password NOT NULL.
+--
+-- Passwords of users registered before hashing are stored in plain text.
+-- They can't be hashed here and bcrypt never matches them, so they are cleared:
+-- such accounts log in again after resetting the password with /auth/forgot-password.
+CREATE TABLE `users_new` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`firstname` text NOT NULL,`secondname` text NOT NULL,`lastname` text NOT NULL,`email` text NOT NULL,`password` text NOT NULL,`phone` text NOT NULL,`role` text NOT NULL,`referrer_id` integer,`status` text NOT NULL DEFAULT "pending",`email_verified_at` datetime,`phone_verified_at` datetime,`token_version` integer NOT NULL DEFAULT 0);
+INSERT INTO `users_new` (`id`,`created_at`,`updated_at`,`deleted_at`,`firstname`,`secondname`,`lastname`,`email`,`password`,`phone`,`role`,`referrer_id`,`status`,`email_verified_at`,`phone_verified_at`)
+SELECT `id`,`created_at`,`updated_at`,`deleted_at`,`firstname`,`secondname`,`lastname`,`email`,CASE WHEN length(`password`) = 60 AND `password` LIKE '$2_$%' THEN `password` ELSE '' END,`phone`,`role`,`referrer_id`,`status`,`email_verified_at`,`phone_verified_at` FROM `users`;
+DROP TABLE `users`;
+ALTER TABLE `users_new` RENAME TO `users`;
+CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
+CREATE UNIQUE INDEX `idx_users_email` ON `users`(`email`) WHERE deleted_at IS NULL;
+CREATE UNIQUE INDEX `idx_users_phone` ON `users`(`phone`) WHERE deleted_at IS NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE `users` ADD COLUMN `totp_secret` text;
+ALTER TABLE `users` ADD COLUMN `totp_enabled` numeric NOT NULL DEFAULT false;
+ALTER TABLE `users` ADD COLUMN `totp_last_step` integer;
+
+CREATE TABLE `recovery_codes` (`id` integer PRIMARY KEY AUTOINCREMENT,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`user_id` integer NOT NULL,`code_hash` text NOT NULL,`used_at` datetime);
+CREATE INDEX `idx_recovery_codes_deleted_at` ON `recovery_codes`(`deleted_at`);
+CREATE INDEX `idx_recovery_codes_user_id` ON `recovery_codes`(`user_id`);
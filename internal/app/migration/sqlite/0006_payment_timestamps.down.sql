@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS `idx_payments_created_at`;
+ALTER TABLE `payments` DROP COLUMN `updated_at`;
+ALTER TABLE `payments` DROP COLUMN `created_at`;
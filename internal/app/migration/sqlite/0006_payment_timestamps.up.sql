@@ -0,0 +1,9 @@
+-- Payments made before timestamps were tracked are dated with the registration
+-- of their user. created_at is NOT NULL, rows without it would never match the
+-- keyset predicate of the list pagination. sqlite only adds NOT NULL columns
+-- with a constant default, it is replaced right away.
+ALTER TABLE `payments` ADD COLUMN `created_at` datetime NOT NULL DEFAULT '1970-01-01 00:00:00+00:00';
+ALTER TABLE `payments` ADD COLUMN `updated_at` datetime;
+UPDATE `payments` SET `created_at` = COALESCE((SELECT `created_at` FROM `users` WHERE `users`.`id` = `payments`.`user_id`), strftime('%Y-%m-%d %H:%M:%f+00:00', 'now'));
+UPDATE `payments` SET `updated_at` = `created_at`;
+CREATE INDEX `idx_payments_created_at` ON `payments`(`created_at`);
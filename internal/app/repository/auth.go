@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sirius_future/internal/app/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (fsr *futureSiriusRepository) GetUserByEmail(ctx context.Context, email string) (*entity.User, error) {
+	var user entity.User
+	if err := fsr.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.NotFound("user", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching user by email", err)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// ResetUserPassword stores the new password hash and bumps the token version,
+// which revokes every access token issued before the reset
+func (fsr *futureSiriusRepository) ResetUserPassword(ctx context.Context, id uint, passwordHash string) error {
+	err := fsr.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"password":      passwordHash,
+		"token_version": gorm.Expr("token_version + 1"),
+	}).Error
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error resetting user password", err, "userID", id)
+		return err
+	}
+
+	fsr.log.InfoContext(ctx, "User password reset, sessions revoked", "userID", id)
+	return nil
+}
+
+// SetUserTOTPSecret stores a new, not yet confirmed TOTP secret
+func (fsr *futureSiriusRepository) SetUserTOTPSecret(ctx context.Context, id uint, encryptedSecret string) error {
+	err := fsr.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"totp_secret":    encryptedSecret,
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+	}).Error
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error saving TOTP secret", err, "userID", id)
+		return err
+	}
+
+	fsr.log.InfoContext(ctx, "TOTP enrolment started", "userID", id)
+	return nil
+}
+
+// EnableUserTOTP turns the second factor on and replaces the recovery codes
+func (fsr *futureSiriusRepository) EnableUserTOTP(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) error {
+	err := fsr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entity.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"totp_enabled":   true,
+			"totp_last_step": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", id).Delete(&entity.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]entity.RecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, entity.RecoveryCode{UserID: id, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error enabling TOTP", err, "userID", id)
+		return err
+	}
+
+	fsr.log.InfoContext(ctx, "TOTP enabled", "userID", id)
+	return nil
+}
+
+// UpdateTOTPLastStep moves the last used step forward. It returns false when
+// the step was already used by a concurrent login.
+func (fsr *futureSiriusRepository) UpdateTOTPLastStep(ctx context.Context, id uint, step int64) (bool, error) {
+	result := fsr.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		fsr.log.ErrorContext(ctx, "Error updating TOTP step", result.Error, "userID", id)
+		return false, result.Error
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode consumes the recovery code, it returns false when the code
+// does not exist or was already used
+func (fsr *futureSiriusRepository) UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error) {
+	result := fsr.db.WithContext(ctx).Model(&entity.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		fsr.log.ErrorContext(ctx, "Error using recovery code", result.Error, "userID", userID)
+		return false, result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		fsr.log.InfoContext(ctx, "Recovery code used", "userID", userID)
+	}
+	return result.RowsAffected > 0, nil
+}
@@ -1,27 +1,53 @@
 package repository
 
 import (
+	"context"
 	"errors"
+	"regexp"
 	"sirius_future/internal/app/entity"
 	"sirius_future/internal/app/service"
+	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"gorm.io/gorm"
 )
 
-type FutureSiriusRepository interface {
-	CreateLink(link *entity.Link) error
-	CreateUser(user *entity.User) error
-	CheckTheLink(url string) (bool, error)
-
-	GetAllUsers() ([]entity.User, error)
+const pgUniqueViolation = "23505"
 
-	GetAllLinks() ([]entity.Link, error)
-	GetReferrerByUrl(url string) (*entity.User, error)
+var pgKeyDetail = regexp.MustCompile(`^Key \(([^)]+)\)=`)
 
-	CreatePayment(payment *entity.Payment) error
-	GetAllPayments() ([]entity.Payment, error)
-	GetPaymentsByUserID(id uint) ([]entity.Payment, error)
-	UpdatePayment(id uint, payment *entity.Payment) error
+type FutureSiriusRepository interface {
+	CreateLink(ctx context.Context, link *entity.Link) error
+	CreateUser(ctx context.Context, user *entity.User) error
+	CheckTheLink(ctx context.Context, url string) (string, error)
+
+	ListUsers(ctx context.Context, filter entity.UserFilter, page entity.PageRequest) (*entity.Page[entity.User], error)
+
+	ListLinks(ctx context.Context, filter entity.LinkFilter, page entity.PageRequest) (*entity.Page[entity.Link], error)
+	GetReferrerByUrl(ctx context.Context, url string) (*entity.User, error)
+
+	CreatePayment(ctx context.Context, payment *entity.Payment) error
+	ListPayments(ctx context.Context, filter entity.PaymentFilter, page entity.PageRequest) (*entity.Page[entity.Payment], error)
+	UpdatePayment(ctx context.Context, id uint, payment *entity.Payment) (previous *entity.Payment, updated *entity.Payment, err error)
+
+	GetUserByID(ctx context.Context, id uint) (*entity.User, error)
+	CreateVerification(ctx context.Context, verification *entity.Verification) error
+	GetActiveVerification(ctx context.Context, userID uint, channel string) (*entity.Verification, error)
+	GetVerificationByHash(ctx context.Context, channel string, hash string) (*entity.Verification, error)
+	UseVerification(ctx context.Context, id uint) (bool, error)
+	GetVerificationAttempts(ctx context.Context, userID uint, channel string, since time.Time) (uint, error)
+	AddVerificationAttempt(ctx context.Context, id uint, limit uint) (bool, error)
+	InvalidateVerifications(ctx context.Context, userID uint, channel string) error
+	ConfirmContact(ctx context.Context, userID uint, channel string, at time.Time) (*entity.User, error)
+
+	GetUserByEmail(ctx context.Context, email string) (*entity.User, error)
+	ResetUserPassword(ctx context.Context, id uint, passwordHash string) error
+
+	SetUserTOTPSecret(ctx context.Context, id uint, encryptedSecret string) error
+	EnableUserTOTP(ctx context.Context, id uint, step int64, recoveryCodeHashes []string) error
+	UpdateTOTPLastStep(ctx context.Context, id uint, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID uint, codeHash string) (bool, error)
 }
 
 type futureSiriusRepository struct {
@@ -33,44 +59,27 @@ func NewFutureSiriusRepository(db *gorm.DB, log service.LoggerService) *futureSi
 	return &futureSiriusRepository{db: db, log: log}
 }
 
-func (fsr *futureSiriusRepository) GetPaymentsByUserID(id uint) ([]entity.Payment, error) {
-	var payments []entity.Payment
-	if err := fsr.db.Preload("User").Where("user_id = ?", id).Find(&payments).Error; err != nil {
-		fsr.log.Error("Error fetching payments for user ID", err, "userID", id)
-		return nil, err
-	}
-
-	fsr.log.Info("Successfully fetched payments for user ID", "userID", id, "count", len(payments))
-	return payments, nil
-}
-
-func (fsr *futureSiriusRepository) GetAllPayments() ([]entity.Payment, error) {
-	var payments []entity.Payment
-	if err := fsr.db.Preload("User").Find(&payments).Error; err != nil {
-		fsr.log.Error("Error fetching all payments", err)
-		return nil, err
-	}
-
-	fsr.log.Info("Successfully fetched all payments", "count", len(payments))
-	return payments, nil
-}
-
-func (fsr *futureSiriusRepository) CreatePayment(payment *entity.Payment) error {
-	if err := fsr.db.Create(payment).Error; err != nil {
-		fsr.log.Error("Error creating payment", err, "payment", payment)
+func (fsr *futureSiriusRepository) CreatePayment(ctx context.Context, payment *entity.Payment) error {
+	if err := fsr.db.WithContext(ctx).Create(payment).Error; err != nil {
+		fsr.log.ErrorContext(ctx, "Error creating payment", err, "payment", payment)
 		return err
 	}
 
-	fsr.log.Info("Payment created successfully", "paymentID", payment.ID)
+	fsr.log.InfoContext(ctx, "Payment created successfully", "paymentID", payment.ID)
 	return nil
 }
 
-func (fsr *futureSiriusRepository) UpdatePayment(id uint, payment *entity.Payment) error {
+// UpdatePayment returns the payment before and after the update
+func (fsr *futureSiriusRepository) UpdatePayment(ctx context.Context, id uint, payment *entity.Payment) (*entity.Payment, *entity.Payment, error) {
 	var ePayment *entity.Payment
-	if err := fsr.db.First(&ePayment, id).Error; err != nil {
-		fsr.log.Error("Error finding payment for update", err, "paymentID", id)
-		return err
+	if err := fsr.db.WithContext(ctx).First(&ePayment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, entity.NotFound("payment", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error finding payment for update", err, "paymentID", id)
+		return nil, nil, err
 	}
+	previous := *ePayment
 
 	// Обновляем значения только если они заданы
 	if payment.Amount != 0 {
@@ -83,103 +92,159 @@ func (fsr *futureSiriusRepository) UpdatePayment(id uint, payment *entity.Paymen
 		ePayment.Status = payment.Status
 	}
 
-	if err := fsr.db.Save(&ePayment).Error; err != nil {
-		fsr.log.Error("Error updating payment", err, "paymentID", id)
-		return err
+	if err := fsr.db.WithContext(ctx).Save(&ePayment).Error; err != nil {
+		fsr.log.ErrorContext(ctx, "Error updating payment", err, "paymentID", id)
+		return nil, nil, err
 	}
 
-	fsr.log.Info("Payment updated successfully", "paymentID", id)
-	return nil
+	fsr.log.InfoContext(ctx, "Payment updated successfully", "paymentID", id)
+	return &previous, ePayment, nil
 }
 
-func (fsr *futureSiriusRepository) GetAllLinks() ([]entity.Link, error) {
-	var links []entity.Link
-	if err := fsr.db.Find(&links).Error; err != nil {
-		fsr.log.Error("Error fetching all links", err)
-		return nil, err
-	}
-
-	fsr.log.Info("Successfully fetched all links", "count", len(links))
-	return links, nil
-}
-
-func (fsr *futureSiriusRepository) GetAllUsers() ([]entity.User, error) {
-	var users []entity.User
-	if err := fsr.db.Find(&users).Error; err != nil {
-		fsr.log.Error("Error fetching all users", err)
-		return nil, err
-	}
-
-	fsr.log.Info("Successfully fetched all users", "count", len(users))
-	return users, nil
-}
-
-func (fsr *futureSiriusRepository) GetReferrerByUrl(url string) (*entity.User, error) {
+func (fsr *futureSiriusRepository) GetReferrerByUrl(ctx context.Context, url string) (*entity.User, error) {
 	var link entity.Link
-	if err := fsr.db.Where("url = ?", url).Find(&link).Error; err != nil {
-		fsr.log.Error("Error fetching link by URL", err, "url", url)
+	if err := fsr.db.WithContext(ctx).Where("url = ?", url).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.NotFound("link", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching link by URL", err, "url", url)
 		return nil, err
 	}
 
 	var user *entity.User
-	if err := fsr.db.First(&user, link.ReferrerID).Error; err != nil {
-		fsr.log.Error("Error fetching referrer by URL", err, "url", url)
+	if err := fsr.db.WithContext(ctx).First(&user, link.ReferrerID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.NotFound("referrer", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching referrer by URL", err, "url", url)
 		return nil, err
 	}
 
-	fsr.log.Info("Successfully fetched referrer by URL", "url", url, "referrerID", user.ID)
+	fsr.log.InfoContext(ctx, "Successfully fetched referrer by URL", "url", url, "referrerID", user.ID)
 	return user, nil
 }
 
-func (fsr *futureSiriusRepository) CreateLink(link *entity.Link) error {
-	if err := fsr.db.Create(link).Error; err != nil {
-		fsr.log.Error("Error creating link", err, "link", link)
+func (fsr *futureSiriusRepository) CreateLink(ctx context.Context, link *entity.Link) error {
+	if err := fsr.db.WithContext(ctx).Create(link).Error; err != nil {
+		fsr.log.ErrorContext(ctx, "Error creating link", err, "link", link)
 		return err
 	}
 
-	fsr.log.Info("Link created successfully", "linkID", link.ID)
+	fsr.log.InfoContext(ctx, "Link created successfully", "linkID", link.ID)
 	return nil
 }
 
-func (fsr *futureSiriusRepository) CreateUser(user *entity.User) error {
-	if err := fsr.db.Create(user).Error; err != nil {
-		fsr.log.Error("Error creating user", err, "user", user)
+func (fsr *futureSiriusRepository) CreateUser(ctx context.Context, user *entity.User) error {
+	if err := fsr.checkUserUnique(ctx, user); err != nil {
+		return err
+	}
+
+	if err := fsr.db.WithContext(ctx).Create(user).Error; err != nil {
+		if field, ok := uniqueViolationField(err); ok {
+			fsr.log.InfoContext(ctx, "User already exists", "field", field)
+			return entity.Conflict(field)
+		}
+		fsr.log.ErrorContext(ctx, "Error creating user", err, "user", user)
 		return err
 	}
 
-	fsr.log.Info("User created successfully", "userID", user.ID)
+	fsr.log.InfoContext(ctx, "User created successfully", "userID", user.ID)
 	return nil
 }
 
-func (fsr *futureSiriusRepository) CheckTheLink(url string) (bool, error) {
+// CheckTheLink redeems the link and returns the outcome, one of entity.LinkRedemption*.
+// The count is increased only while it is below the limit, so concurrent
+// redemptions can't exceed it.
+func (fsr *futureSiriusRepository) CheckTheLink(ctx context.Context, url string) (string, error) {
 	var link entity.Link
 
-	result := fsr.db.Where("url = ?", url).Find(&link)
+	result := fsr.db.WithContext(ctx).Where("url = ?", url).Limit(1).Find(&link)
 	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			fsr.log.Info("Link not found", "url", url)
-			return false, nil
-		}
-		fsr.log.Error("Error fetching link by URL", result.Error, "url", url)
-		return false, result.Error
+		fsr.log.ErrorContext(ctx, "Error fetching link by URL", result.Error, "url", url)
+		return "", result.Error
+	}
+	if result.RowsAffected == 0 {
+		fsr.log.InfoContext(ctx, "Link not found", "url", url)
+		return entity.LinkRedemptionUnknown, nil
 	}
 
-	if url != link.Url {
-		fsr.log.Error("URL mismatch", errors.New("URL mismatch"), "providedUrl", url, "foundUrl", link.Url)
-		return false, errors.New("Error")
+	if !link.Status {
+		fsr.log.InfoContext(ctx, "Link is disabled", "url", url)
+		return entity.LinkRedemptionDisabled, nil
 	}
 
-	if link.Limit <= link.Count {
-		fsr.log.Info("Link usage limit reached", "url", url, "limit", link.Limit, "count", link.Count)
-		return false, nil
+	count, limit := fsr.db.Statement.Quote("count"), fsr.db.Statement.Quote("limit")
+	update := fsr.db.WithContext(ctx).Model(&link).Where(count+" < "+limit).Update("count", gorm.Expr(count+" + 1"))
+	if update.Error != nil {
+		fsr.log.ErrorContext(ctx, "Error updating link count", update.Error, "url", url)
+		return "", update.Error
+	}
+	if update.RowsAffected == 0 {
+		fsr.log.InfoContext(ctx, "Link usage limit reached", "url", url, "limit", link.Limit, "count", link.Count)
+		return entity.LinkRedemptionExhausted, nil
 	}
 
-	link.Count += 1
-	if err := fsr.db.Save(&link).Error; err != nil {
-		fsr.log.Error("Error updating link count", err, "url", url)
-		return false, err
+	fsr.log.InfoContext(ctx, "Link used successfully", "url", url, "newCount", link.Count+1)
+	return entity.LinkRedemptionSuccess, nil
+}
+
+// checkUserUnique rejects the user if another active (not soft-deleted) user
+// already has the same email or phone
+func (fsr *futureSiriusRepository) checkUserUnique(ctx context.Context, user *entity.User) error {
+	unique := []struct {
+		field string
+		value string
+	}{
+		{"email", user.Email},
+		{"phone", user.Phone},
+	}
+
+	for _, u := range unique {
+		var count int64
+		if err := fsr.db.WithContext(ctx).Model(&entity.User{}).Where(u.field+" = ?", u.value).Count(&count).Error; err != nil {
+			fsr.log.ErrorContext(ctx, "Error checking user uniqueness", err, "field", u.field)
+			return err
+		}
+		if count > 0 {
+			fsr.log.InfoContext(ctx, "User already exists", "field", u.field)
+			return entity.Conflict(u.field)
+		}
+	}
+	return nil
+}
+
+// uniqueViolationField extracts the column name from a unique constraint error
+// of either driver, so both report the same conflict:
+// sqlite "UNIQUE constraint failed: users.email" -> "email",
+// postgres 23505 with detail "Key (email)=(a@b.c) already exists." -> "email"
+func uniqueViolationField(err error) (string, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code != pgUniqueViolation {
+			return "", false
+		}
+		if match := pgKeyDetail.FindStringSubmatch(pgErr.Detail); match != nil {
+			return strings.TrimSpace(strings.SplitN(match[1], ",", 2)[0]), true
+		}
+		// the detail is hidden for some server settings, the indexes are named
+		// <prefix>_<table>_<column>: idx_users_email, uni_links_url
+		if _, column, found := strings.Cut(pgErr.ConstraintName, "_"+pgErr.TableName+"_"); found && pgErr.TableName != "" {
+			return column, true
+		}
+		return pgErr.ConstraintName, true
+	}
+
+	const marker = "UNIQUE constraint failed: "
+
+	msg := err.Error()
+	idx := strings.Index(msg, marker)
+	if idx < 0 {
+		return "", false
 	}
 
-	fsr.log.Info("Link used successfully", "url", url, "newCount", link.Count)
-	return true, nil
+	column := strings.SplitN(msg[idx+len(marker):], ",", 2)[0]
+	if dot := strings.LastIndex(column, "."); dot >= 0 {
+		column = column[dot+1:]
+	}
+	return strings.TrimSpace(column), true
 }
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestUniqueViolationField(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		field  string
+		unique bool
+	}{
+		{
+			name:   "postgres detail",
+			err:    &pgconn.PgError{Code: pgUniqueViolation, Detail: "Key (email)=(ivan@mail.ru) already exists.", TableName: "users", ConstraintName: "idx_users_email"},
+			field:  "email",
+			unique: true,
+		},
+		{
+			name:   "postgres detail of a composite key",
+			err:    &pgconn.PgError{Code: pgUniqueViolation, Detail: "Key (user_id, code_hash)=(1, abc) already exists.", TableName: "recovery_codes", ConstraintName: "idx_recovery_codes_user_code"},
+			field:  "user_id",
+			unique: true,
+		},
+		{
+			name:   "postgres without detail",
+			err:    &pgconn.PgError{Code: pgUniqueViolation, TableName: "users", ConstraintName: "idx_users_phone"},
+			field:  "phone",
+			unique: true,
+		},
+		{
+			name:   "postgres without detail, table with underscores",
+			err:    &pgconn.PgError{Code: pgUniqueViolation, TableName: "referral_rewards", ConstraintName: "idx_referral_rewards_referred_id"},
+			field:  "referred_id",
+			unique: true,
+		},
+		{
+			name:   "postgres without detail, constraint of another naming",
+			err:    &pgconn.PgError{Code: pgUniqueViolation, TableName: "users", ConstraintName: "users_email_key"},
+			field:  "users_email_key",
+			unique: true,
+		},
+		{
+			name:   "postgres wrapped",
+			err:    fmt.Errorf("create user: %w", &pgconn.PgError{Code: pgUniqueViolation, Detail: "Key (phone)=(+79991234567) already exists."}),
+			field:  "phone",
+			unique: true,
+		},
+		{
+			name: "postgres other violation",
+			err:  &pgconn.PgError{Code: "23503", Detail: "Key (user_id)=(7) is not present in table \"users\".", TableName: "payments"},
+		},
+		{
+			name:   "sqlite",
+			err:    errors.New("UNIQUE constraint failed: users.email"),
+			field:  "email",
+			unique: true,
+		},
+		{
+			name:   "sqlite composite key",
+			err:    errors.New("UNIQUE constraint failed: links.url, links.referrer_id"),
+			field:  "url",
+			unique: true,
+		},
+		{
+			name: "sqlite other error",
+			err:  errors.New("NOT NULL constraint failed: users.email"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			field, unique := uniqueViolationField(tt.err)
+			if field != tt.field || unique != tt.unique {
+				t.Errorf("uniqueViolationField() = %q, %v, want %q, %v", field, unique, tt.field, tt.unique)
+			}
+		})
+	}
+}
+
+var (
+	uniqueIndex      = regexp.MustCompile(`CREATE UNIQUE INDEX "(\w+)" ON "(\w+)" \("(\w+)"\)`)
+	uniqueConstraint = regexp.MustCompile(`CREATE TABLE (?:IF NOT EXISTS )?"(\w+)" \(.*CONSTRAINT "(\w+)" UNIQUE \("(\w+)"\)`)
+)
+
+// The fallback without the detail relies on the names of the unique indexes
+// and constraints in the postgres migrations
+func TestUniqueViolationFieldOfMigrationIndexes(t *testing.T) {
+	files, err := filepath.Glob("../migration/postgres/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no postgres migrations found: %v", err)
+	}
+
+	checked := 0
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, match := range uniqueIndex.FindAllStringSubmatch(string(data), -1) {
+			checkIndexName(t, match[1], match[2], match[3])
+			checked++
+		}
+		for _, match := range uniqueConstraint.FindAllStringSubmatch(string(data), -1) {
+			checkIndexName(t, match[2], match[1], match[3])
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no unique indexes found in the postgres migrations")
+	}
+}
+
+func checkIndexName(t *testing.T, index string, table string, column string) {
+	t.Helper()
+
+	field, unique := uniqueViolationField(&pgconn.PgError{Code: pgUniqueViolation, TableName: table, ConstraintName: index})
+	if !unique || field != column {
+		t.Errorf("index %s on %s(%s) is reported as %q", index, table, column, field)
+	}
+}
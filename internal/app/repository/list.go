@@ -0,0 +1,272 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	sortKindNumber = "number"
+	sortKindTime   = "time"
+	sortKindString = "string"
+)
+
+// sortField is a whitelisted sort field of a list, kind tells how to decode
+// the cursor value
+type sortField struct {
+	column string
+	kind   string
+}
+
+var (
+	userSortFields = map[string]sortField{
+		"id":         {"id", sortKindNumber},
+		"created_at": {"created_at", sortKindTime},
+		"email":      {"email", sortKindString},
+		"last_name":  {"lastname", sortKindString},
+	}
+
+	linkSortFields = map[string]sortField{
+		"id":         {"id", sortKindNumber},
+		"created_at": {"created_at", sortKindTime},
+		"count":      {"count", sortKindNumber},
+		"limit":      {"limit", sortKindNumber},
+	}
+
+	paymentSortFields = map[string]sortField{
+		"id":         {"id", sortKindNumber},
+		"created_at": {"created_at", sortKindTime},
+		"amount":     {"amount", sortKindNumber},
+	}
+)
+
+// pageCursor points at the last row of a page: its sort value and id
+type pageCursor struct {
+	Value interface{} `json:"v"`
+	ID    uint        `json:"id"`
+}
+
+func (fsr *futureSiriusRepository) ListUsers(ctx context.Context, filter entity.UserFilter, page entity.PageRequest) (*entity.Page[entity.User], error) {
+	query := fsr.db.WithContext(ctx).Model(&entity.User{})
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+	if filter.ReferrerID != nil {
+		query = query.Where("referrer_id = ?", *filter.ReferrerID)
+	}
+
+	result, err := listPage(query, page, userSortFields, func(user entity.User, column string) interface{} {
+		switch column {
+		case "created_at":
+			return user.CreatedAt
+		case "email":
+			return user.Email
+		case "lastname":
+			return user.Lastname
+		}
+		return user.ID
+	}, func(user entity.User) uint { return user.ID })
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error listing users", err)
+		return nil, err
+	}
+
+	fsr.log.InfoContext(ctx, "Successfully listed users", "count", len(result.Items))
+	return result, nil
+}
+
+func (fsr *futureSiriusRepository) ListLinks(ctx context.Context, filter entity.LinkFilter, page entity.PageRequest) (*entity.Page[entity.Link], error) {
+	query := fsr.db.WithContext(ctx).Model(&entity.Link{})
+	if filter.ReferrerID != nil {
+		query = query.Where("referrer_id = ?", *filter.ReferrerID)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+	if filter.Exhausted != nil {
+		count, limit := query.Statement.Quote("count"), query.Statement.Quote("limit")
+		if *filter.Exhausted {
+			query = query.Where(fmt.Sprintf("%s >= %s", count, limit))
+		} else {
+			query = query.Where(fmt.Sprintf("%s < %s", count, limit))
+		}
+	}
+
+	result, err := listPage(query, page, linkSortFields, func(link entity.Link, column string) interface{} {
+		switch column {
+		case "created_at":
+			return link.CreatedAt
+		case "count":
+			return link.Count
+		case "limit":
+			return link.Limit
+		}
+		return link.ID
+	}, func(link entity.Link) uint { return link.ID })
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error listing links", err)
+		return nil, err
+	}
+
+	fsr.log.InfoContext(ctx, "Successfully listed links", "count", len(result.Items))
+	return result, nil
+}
+
+func (fsr *futureSiriusRepository) ListPayments(ctx context.Context, filter entity.PaymentFilter, page entity.PageRequest) (*entity.Page[entity.Payment], error) {
+	query := fsr.db.WithContext(ctx).Model(&entity.Payment{})
+	if filter.UserID != nil {
+		query = query.Where("user_id = ?", *filter.UserID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.From != nil {
+		query = query.Where("created_at >= ?", *filter.From)
+	}
+	if filter.To != nil {
+		query = query.Where("created_at < ?", *filter.To)
+	}
+
+	result, err := listPage(query, page, paymentSortFields, func(payment entity.Payment, column string) interface{} {
+		switch column {
+		case "created_at":
+			return payment.CreatedAt
+		case "amount":
+			return payment.Amount
+		}
+		return payment.ID
+	}, func(payment entity.Payment) uint { return payment.ID })
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error listing payments", err)
+		return nil, err
+	}
+
+	fsr.log.InfoContext(ctx, "Successfully listed payments", "count", len(result.Items))
+	return result, nil
+}
+
+// listPage runs the filtered query with keyset pagination on (sort field, id),
+// so pages stay stable while rows are inserted
+func listPage[T any](query *gorm.DB, page entity.PageRequest, fields map[string]sortField, sortValue func(T, string) interface{}, id func(T) uint) (*entity.Page[T], error) {
+	name, desc := strings.CutPrefix(page.Sort, "-")
+	if name == "" {
+		name = "id"
+	}
+	field, ok := fields[name]
+	if !ok {
+		return nil, entity.ErrInvalidSort
+	}
+
+	limit := page.Limit
+	if limit <= 0 {
+		limit = entity.DefaultPageLimit
+	}
+	if limit > entity.MaxPageLimit {
+		limit = entity.MaxPageLimit
+	}
+
+	result := &entity.Page[T]{Items: []T{}}
+	if page.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		result.Total = &total
+	}
+
+	column := query.Statement.Quote(field.column)
+	direction, compare := "ASC", ">"
+	if desc {
+		direction, compare = "DESC", "<"
+	}
+
+	if page.Cursor != "" {
+		cursor, err := decodeCursor(page.Cursor, field.kind)
+		if err != nil {
+			return nil, err
+		}
+		if field.column == "id" {
+			query = query.Where(fmt.Sprintf("id %s ?", compare), cursor.ID)
+		} else {
+			query = query.Where(fmt.Sprintf("%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?)", column, compare), cursor.Value, cursor.Value, cursor.ID)
+		}
+	}
+
+	query = query.Order(fmt.Sprintf("%s %s", column, direction))
+	if field.column != "id" {
+		query = query.Order("id " + direction)
+	}
+
+	if err := query.Limit(limit + 1).Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+
+	if len(result.Items) > limit {
+		result.Items = result.Items[:limit]
+		last := result.Items[limit-1]
+
+		cursor, err := encodeCursor(pageCursor{Value: sortValue(last, field.column), ID: id(last)})
+		if err != nil {
+			return nil, err
+		}
+		result.NextCursor = cursor
+	}
+
+	return result, nil
+}
+
+func encodeCursor(cursor pageCursor) (string, error) {
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+func decodeCursor(encoded string, kind string) (*pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, entity.ErrInvalidCursor
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, entity.ErrInvalidCursor
+	}
+
+	switch kind {
+	case sortKindTime:
+		value, ok := cursor.Value.(string)
+		if !ok {
+			return nil, entity.ErrInvalidCursor
+		}
+		parsed, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, entity.ErrInvalidCursor
+		}
+		cursor.Value = parsed
+	case sortKindNumber:
+		if _, ok := cursor.Value.(float64); !ok {
+			return nil, entity.ErrInvalidCursor
+		}
+	case sortKindString:
+		if _, ok := cursor.Value.(string); !ok {
+			return nil, entity.ErrInvalidCursor
+		}
+	}
+
+	return &cursor, nil
+}
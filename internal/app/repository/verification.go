@@ -0,0 +1,184 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func (fsr *futureSiriusRepository) GetUserByID(ctx context.Context, id uint) (*entity.User, error) {
+	var user entity.User
+	if err := fsr.db.WithContext(ctx).First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.NotFound("user", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching user by ID", err, "userID", id)
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (fsr *futureSiriusRepository) CreateVerification(ctx context.Context, verification *entity.Verification) error {
+	if err := fsr.db.WithContext(ctx).Create(verification).Error; err != nil {
+		fsr.log.ErrorContext(ctx, "Error creating verification", err, "userID", verification.UserID, "channel", verification.Channel)
+		return err
+	}
+
+	fsr.log.InfoContext(ctx, "Verification created successfully", "userID", verification.UserID, "channel", verification.Channel)
+	return nil
+}
+
+// GetActiveVerification returns the latest unused and not expired verification of the user
+func (fsr *futureSiriusRepository) GetActiveVerification(ctx context.Context, userID uint, channel string) (*entity.Verification, error) {
+	var verification entity.Verification
+	err := fsr.db.WithContext(ctx).
+		Where("user_id = ? AND channel = ? AND used_at IS NULL AND expires_at > ?", userID, channel, time.Now()).
+		Order("id DESC").
+		First(&verification).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrVerificationInvalid
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching verification", err, "userID", userID, "channel", channel)
+		return nil, err
+	}
+
+	return &verification, nil
+}
+
+func (fsr *futureSiriusRepository) GetVerificationByHash(ctx context.Context, channel string, hash string) (*entity.Verification, error) {
+	var verification entity.Verification
+	err := fsr.db.WithContext(ctx).
+		Where("channel = ? AND code_hash = ? AND used_at IS NULL AND expires_at > ?", channel, hash, time.Now()).
+		First(&verification).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.ErrVerificationInvalid
+		}
+		fsr.log.ErrorContext(ctx, "Error fetching verification by hash", err, "channel", channel)
+		return nil, err
+	}
+
+	return &verification, nil
+}
+
+// UseVerification consumes the verification, it returns false when the
+// verification was already used, so a code is accepted only once even by
+// concurrent requests
+func (fsr *futureSiriusRepository) UseVerification(ctx context.Context, id uint) (bool, error) {
+	result := fsr.db.WithContext(ctx).Model(&entity.Verification{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		fsr.log.ErrorContext(ctx, "Error using verification", result.Error, "verificationID", id)
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// GetVerificationAttempts returns the attempts of the latest code of the channel
+// issued since the given time, used or not, and 0 when there is none
+func (fsr *futureSiriusRepository) GetVerificationAttempts(ctx context.Context, userID uint, channel string, since time.Time) (uint, error) {
+	var verification entity.Verification
+	err := fsr.db.WithContext(ctx).
+		Where("user_id = ? AND channel = ? AND created_at > ?", userID, channel, since).
+		Order("id DESC").
+		Limit(1).
+		Find(&verification).Error
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error fetching verification attempts", err, "userID", userID, "channel", channel)
+		return 0, err
+	}
+
+	return verification.Attempts, nil
+}
+
+// AddVerificationAttempt counts an attempt to enter the code, it returns false
+// when the limit is already reached. The check and the increment are one
+// statement, so concurrent guesses can't exceed the limit.
+func (fsr *futureSiriusRepository) AddVerificationAttempt(ctx context.Context, id uint, limit uint) (bool, error) {
+	result := fsr.db.WithContext(ctx).Model(&entity.Verification{}).
+		Where("id = ? AND attempts < ?", id, limit).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		fsr.log.ErrorContext(ctx, "Error adding verification attempt", result.Error, "verificationID", id)
+		return false, result.Error
+	}
+
+	return result.RowsAffected > 0, nil
+}
+
+// InvalidateVerifications marks all pending codes of the channel as used, so only
+// the newly issued one is valid
+func (fsr *futureSiriusRepository) InvalidateVerifications(ctx context.Context, userID uint, channel string) error {
+	err := fsr.db.WithContext(ctx).Model(&entity.Verification{}).
+		Where("user_id = ? AND channel = ? AND used_at IS NULL", userID, channel).
+		Update("used_at", time.Now()).Error
+	if err != nil {
+		fsr.log.ErrorContext(ctx, "Error invalidating verifications", err, "userID", userID, "channel", channel)
+		return err
+	}
+
+	return nil
+}
+
+// verifiedColumns are the user columns confirmed by the verification channels
+var verifiedColumns = map[string]string{
+	entity.VerificationChannelEmail: "email_verified_at",
+	entity.VerificationChannelSMS:   "phone_verified_at",
+}
+
+// ConfirmContact marks the contact of the channel as confirmed. The user is
+// activated once both contacts are confirmed and the referrer, if any, is
+// rewarded in the same transaction. Only the changed columns are written under
+// conditions, so concurrent email and SMS confirmations don't overwrite each
+// other and only one of them activates the user.
+func (fsr *futureSiriusRepository) ConfirmContact(ctx context.Context, userID uint, channel string, at time.Time) (*entity.User, error) {
+	column, ok := verifiedColumns[channel]
+	if !ok {
+		return nil, fmt.Errorf("channel %q doesn't confirm a contact", channel)
+	}
+
+	var user entity.User
+	activated := false
+	err := fsr.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&entity.User{}).
+			Where("id = ? AND "+column+" IS NULL", userID).
+			UpdateColumn(column, at).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&entity.User{}).
+			Where("id = ? AND status = ? AND email_verified_at IS NOT NULL AND phone_verified_at IS NOT NULL", userID, entity.UserStatusPending).
+			UpdateColumn("status", entity.UserStatusActive)
+		if result.Error != nil {
+			return result.Error
+		}
+		activated = result.RowsAffected > 0
+
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		if activated && user.ReferrerID != 0 {
+			return tx.Create(&entity.ReferralReward{ReferrerID: user.ReferrerID, ReferredID: user.ID}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, entity.NotFound("user", err)
+		}
+		fsr.log.ErrorContext(ctx, "Error confirming contact", err, "userID", userID, "channel", channel)
+		return nil, err
+	}
+
+	fsr.log.InfoContext(ctx, "Contact confirmed", "userID", userID, "channel", channel, "status", user.Status, "activated", activated)
+	return &user, nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/config"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrInvalidToken = entity.NewError(entity.CodeUnauthorized, "invalid or expired token")
+
+const (
+	TokenPurposeAccess = ""
+	TokenPurposeMFA    = "mfa"
+
+	mfaTokenTTL = 5 * time.Minute
+)
+
+type AuthService interface {
+	HashPassword(password string) (string, error)
+	CheckPassword(hash string, password string) bool
+	GenerateToken(user *entity.User, mfa bool) (string, error)
+	GenerateMFAToken(user *entity.User) (string, error)
+	ParseToken(token string) (*TokenClaims, error)
+	RequiresMFA(role string) bool
+}
+
+// TokenClaims is the payload of the access token. TokenVersion must match
+// User.TokenVersion, bumping it on the user revokes all issued tokens.
+// MFA is set when the second factor was passed during login.
+// Tokens with a Purpose are not access tokens, e.g. the mfa token only
+// allows to finish the login with a TOTP code.
+type TokenClaims struct {
+	UserID       uint   `json:"user_id"`
+	Role         string `json:"role"`
+	TokenVersion uint   `json:"ver"`
+	MFA          bool   `json:"mfa,omitempty"`
+	Purpose      string `json:"purpose,omitempty"`
+	jwt.StandardClaims
+}
+
+type authService struct {
+	secret   []byte
+	ttl      time.Duration
+	mfaRoles map[string]bool
+}
+
+// NewAuthService creates the service, users with one of cfg.MFARoles must pass
+// TOTP two-factor authentication for protected operations
+func NewAuthService(cfg config.AuthConfig) *authService {
+	roles := make(map[string]bool, len(cfg.MFARoles))
+	for _, role := range cfg.MFARoles {
+		roles[role] = true
+	}
+	return &authService{secret: []byte(cfg.JWTSecret), ttl: cfg.TokenTTL, mfaRoles: roles}
+}
+
+func (as *authService) RequiresMFA(role string) bool {
+	return as.mfaRoles[role]
+}
+
+func (as *authService) HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func (as *authService) CheckPassword(hash string, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func (as *authService) GenerateToken(user *entity.User, mfa bool) (string, error) {
+	return as.sign(user, TokenPurposeAccess, mfa, as.ttl)
+}
+
+// GenerateMFAToken issues a short-lived token that is exchanged for an access
+// token together with the TOTP code
+func (as *authService) GenerateMFAToken(user *entity.User) (string, error) {
+	return as.sign(user, TokenPurposeMFA, false, mfaTokenTTL)
+}
+
+func (as *authService) sign(user *entity.User, purpose string, mfa bool, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := TokenClaims{
+		UserID:       user.ID,
+		Role:         user.Role,
+		TokenVersion: user.TokenVersion,
+		MFA:          mfa,
+		Purpose:      purpose,
+		StandardClaims: jwt.StandardClaims{
+			Subject:   fmt.Sprint(user.ID),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(as.secret)
+}
+
+func (as *authService) ParseToken(token string) (*TokenClaims, error) {
+	claims := &TokenClaims{}
+	parsed, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, ErrInvalidToken
+		}
+		return as.secret, nil
+	})
+	if err != nil || !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrCacheMiss = errors.New("cache miss")
+
+// Cache is the key-value store used by the usecase. RedisService, MemoryCache
+// and TieredCache implement it. Get returns ErrCacheMiss for absent keys.
+type Cache interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Incr(ctx context.Context, key string, expiration time.Duration) (int64, error)
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Close() error
+}
+
+type memoryEntry struct {
+	key       string
+	value     string
+	expiresAt time.Time // zero means no expiration
+}
+
+// MemoryCache is an in-process LRU cache with per-key expiration
+type MemoryCache struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List // front is the most recently used
+}
+
+func NewMemoryCache(capacity int) *MemoryCache {
+	return &MemoryCache{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (mc *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, ok := mc.get(key)
+	if !ok {
+		return "", ErrCacheMiss
+	}
+	return entry.value, nil
+}
+
+func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	mc.set(key, cacheString(value), expiration)
+	return nil
+}
+
+func (mc *MemoryCache) Delete(ctx context.Context, key string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	if elem, ok := mc.items[key]; ok {
+		mc.remove(elem)
+	}
+	return nil
+}
+
+func (mc *MemoryCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, ok := mc.get(key)
+	if !ok {
+		mc.set(key, "1", expiration)
+		return 1, nil
+	}
+
+	count, err := strconv.ParseInt(entry.value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value of %s is not an integer", key)
+	}
+	count++
+	entry.value = strconv.FormatInt(count, 10)
+	return count, nil
+}
+
+func (mc *MemoryCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	entry, ok := mc.get(key)
+	if !ok || entry.expiresAt.IsZero() {
+		return 0, nil
+	}
+	return time.Until(entry.expiresAt), nil
+}
+
+func (mc *MemoryCache) Close() error {
+	return nil
+}
+
+// get returns a live entry and marks it as recently used, expired entries are dropped
+func (mc *MemoryCache) get(key string) (*memoryEntry, bool) {
+	elem, ok := mc.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*memoryEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		mc.remove(elem)
+		return nil, false
+	}
+
+	mc.order.MoveToFront(elem)
+	return entry, true
+}
+
+func (mc *MemoryCache) set(key string, value string, expiration time.Duration) {
+	var expiresAt time.Time
+	if expiration > 0 {
+		expiresAt = time.Now().Add(expiration)
+	}
+
+	if elem, ok := mc.items[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		mc.order.MoveToFront(elem)
+		return
+	}
+
+	mc.items[key] = mc.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+
+	for mc.capacity > 0 && mc.order.Len() > mc.capacity {
+		mc.remove(mc.order.Back())
+	}
+}
+
+func (mc *MemoryCache) remove(elem *list.Element) {
+	mc.order.Remove(elem)
+	delete(mc.items, elem.Value.(*memoryEntry).key)
+}
+
+// cacheString converts values the way redis stores them
+func cacheString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// TieredCache writes through to redis and the local cache. When redis is
+// unreachable it serves reads and writes from the local cache and retries
+// redis after retryAfter, so an outage costs latency instead of failed writes.
+// Writes made during the outage are not replayed, the redis copies of the keys
+// written meanwhile are deleted once redis is back instead: they may hold
+// values the outage superseded, e.g. the old version of an invalidated tag.
+type TieredCache struct {
+	remote     Cache
+	local      Cache
+	retryAfter time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+	stale     map[string]struct{} // keys written to the local cache only
+
+	resyncMu sync.Mutex // redis is not used until the stale keys are deleted
+}
+
+func NewTieredCache(remote Cache, local Cache, retryAfter time.Duration) *TieredCache {
+	return &TieredCache{remote: remote, local: local, retryAfter: retryAfter, stale: map[string]struct{}{}}
+}
+
+func (tc *TieredCache) Get(ctx context.Context, key string) (string, error) {
+	if tc.remoteUp(ctx) {
+		value, err := tc.remote.Get(ctx, key)
+		if err == nil || errors.Is(err, ErrCacheMiss) {
+			return value, err
+		}
+		tc.markDown(ctx)
+	}
+	return tc.local.Get(ctx, key)
+}
+
+func (tc *TieredCache) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
+	if tc.remoteUp(ctx) {
+		if err := tc.remote.Set(ctx, key, value, expiration); err == nil {
+			return tc.local.Set(ctx, key, value, expiration)
+		}
+		tc.markDown(ctx)
+	}
+	tc.markStale(key)
+	return tc.local.Set(ctx, key, value, expiration)
+}
+
+func (tc *TieredCache) Delete(ctx context.Context, key string) error {
+	if tc.remoteUp(ctx) {
+		if err := tc.remote.Delete(ctx, key); err == nil {
+			return tc.local.Delete(ctx, key)
+		}
+		tc.markDown(ctx)
+	}
+	tc.markStale(key)
+	return tc.local.Delete(ctx, key)
+}
+
+func (tc *TieredCache) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	if tc.remoteUp(ctx) {
+		count, err := tc.remote.Incr(ctx, key, expiration)
+		if err == nil {
+			return count, nil
+		}
+		tc.markDown(ctx)
+	}
+	return tc.local.Incr(ctx, key, expiration)
+}
+
+func (tc *TieredCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if tc.remoteUp(ctx) {
+		ttl, err := tc.remote.TTL(ctx, key)
+		if err == nil {
+			return ttl, nil
+		}
+		tc.markDown(ctx)
+	}
+	return tc.local.TTL(ctx, key)
+}
+
+func (tc *TieredCache) Close() error {
+	return errors.Join(tc.remote.Close(), tc.local.Close())
+}
+
+// remoteUp reports whether redis can be used. The first call after an outage
+// deletes the stale keys from redis, concurrent calls wait for it.
+func (tc *TieredCache) remoteUp(ctx context.Context) bool {
+	tc.mu.Lock()
+	up := time.Now().After(tc.downUntil)
+	tc.mu.Unlock()
+	if !up {
+		return false
+	}
+
+	tc.resyncMu.Lock()
+	defer tc.resyncMu.Unlock()
+
+	return tc.resync(ctx)
+}
+
+// resync deletes the stale keys from redis, the keys it couldn't delete stay
+// stale and redis is marked down again
+func (tc *TieredCache) resync(ctx context.Context) bool {
+	tc.mu.Lock()
+	stale := tc.stale
+	if len(stale) == 0 {
+		tc.mu.Unlock()
+		return true
+	}
+	tc.stale = map[string]struct{}{}
+	tc.mu.Unlock()
+
+	for key := range stale {
+		if err := tc.remote.Delete(ctx, key); err != nil {
+			tc.mu.Lock()
+			for key := range stale {
+				tc.stale[key] = struct{}{}
+			}
+			tc.mu.Unlock()
+			tc.markDown(ctx)
+			return false
+		}
+		delete(stale, key)
+	}
+	return true
+}
+
+func (tc *TieredCache) markStale(key string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.stale[key] = struct{}{}
+}
+
+// markDown skips redis for retryAfter, unless the call failed because the
+// request was cancelled
+func (tc *TieredCache) markDown(ctx context.Context) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	tc.downUntil = time.Now().Add(tc.retryAfter)
+}
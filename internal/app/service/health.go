@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFail     = "fail"
+)
+
+// HealthCheck probes one dependency. A failed critical check makes the app
+// not ready, a failed non-critical check only degrades it.
+type HealthCheck struct {
+	Name     string
+	Critical bool
+	Check    func(ctx context.Context) error
+}
+
+type CheckResult struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMs float64 `json:"latency_ms"`
+}
+
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready is false when a critical dependency is down
+func (hr *HealthReport) Ready() bool {
+	return hr.Status != HealthStatusFail
+}
+
+type HealthService struct {
+	checks  []HealthCheck
+	timeout time.Duration
+	log     LoggerService
+}
+
+// NewHealthService runs every check with its own timeout. The errors of the
+// checks are logged, the report only tells which dependency failed.
+func NewHealthService(timeout time.Duration, log LoggerService, checks ...HealthCheck) *HealthService {
+	return &HealthService{checks: checks, timeout: timeout, log: log}
+}
+
+// Check runs all checks concurrently, so one slow dependency costs at most the timeout
+func (hs *HealthService) Check(ctx context.Context) *HealthReport {
+	report := &HealthReport{Status: HealthStatusOK, Checks: make(map[string]CheckResult, len(hs.checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range hs.checks {
+		wg.Add(1)
+		go func(check HealthCheck) {
+			defer wg.Done()
+			result := hs.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			switch {
+			case result.Status == HealthStatusOK:
+			case check.Critical:
+				report.Status = HealthStatusFail
+			case report.Status == HealthStatusOK:
+				report.Status = HealthStatusDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+
+	return report
+}
+
+func (hs *HealthService) run(ctx context.Context, check HealthCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, hs.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check.Check(ctx)
+	result := CheckResult{
+		Status:    HealthStatusOK,
+		Critical:  check.Critical,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		hs.log.ErrorContext(ctx, "Health check failed", err, "check", check.Name, "critical", check.Critical)
+	}
+	return result
+}
@@ -1,21 +1,27 @@
 package service
 
 import (
+	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
-	"errors"
+	"encoding/hex"
 	"fmt"
+	"math/big"
 	"sirius_future/internal/app/entity"
 
-	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type FutureSiriusService interface {
-	CheckUserByID(id uint) error
 	GenerateRefferalLink(userID uint) string
-	UserValidate(user *entity.User) []string
+	UserValidate(user *entity.User) error
+	ValidateRequest(ctx context.Context, request any) error
+
+	GenerateVerificationToken() (string, error)
+	GenerateOTP() (string, error)
+	HashToken(token string) string
 }
 
 type futureSiriusService struct {
@@ -28,28 +34,10 @@ func NewFutureSiriusService(db *gorm.DB) *futureSiriusService {
 	}
 }
 
-func (fss *futureSiriusService) UserValidate(user *entity.User) []string {
-	var errorMessages []string
+// UserValidate returns a validation error listing every rule the user breaks
+func (fss *futureSiriusService) UserValidate(user *entity.User) error {
 	if err := user.Validate(); err != nil {
-		validationErrors := err.(validator.ValidationErrors)
-
-		for _, err := range validationErrors {
-			errorMessages = append(errorMessages, fmt.Sprintf("Field '%s' Error: %s", err.Field(), err.Tag()))
-		}
-
-		return errorMessages
-	}
-	return errorMessages
-}
-
-func (fss *futureSiriusService) CheckUserByID(id uint) error {
-	var user entity.User
-	if err := fss.db.First(&user, id).Error; err != nil {
-		return err
-	}
-
-	if user.ID != id {
-		return errors.New("user not found")
+		return entity.ValidationFailed(err)
 	}
 	return nil
 }
@@ -64,3 +52,27 @@ func (fss *futureSiriusService) GenerateRefferalLink(userID uint) string {
 
 	return encodedHash
 }
+
+// GenerateVerificationToken returns a random url-safe token sent by email
+func (fss *futureSiriusService) GenerateVerificationToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// GenerateOTP returns a random 6-digit code sent by SMS
+func (fss *futureSiriusService) GenerateOTP() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// HashToken is used to store tokens and codes, never the raw value
+func (fss *futureSiriusService) HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
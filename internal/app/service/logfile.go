@@ -0,0 +1,213 @@
+package service
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sirius_future/internal/config"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat sorts lexicographically in time order
+const backupTimeFormat = "20060102T150405.000"
+
+// rotatingFile is a log file rotated by size and age. Rotated files are
+// renamed to <name>-<time><ext>, then compressed and pruned in the background.
+// The age is counted from when the file was opened, so a restart starts it over.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	retainFor  time.Duration
+	compress   bool
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+
+	millMu sync.Mutex     // serializes compression and pruning
+	mill   sync.WaitGroup // waited for on Close
+}
+
+func openRotatingFile(cfg config.LogConfig) (*rotatingFile, error) {
+	rf := &rotatingFile{
+		path:       cfg.File,
+		maxSize:    int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxAge:     cfg.MaxAge,
+		maxBackups: cfg.MaxBackups,
+		retainFor:  cfg.RetainFor,
+		compress:   cfg.Compress,
+	}
+	if err := os.MkdirAll(filepath.Dir(rf.path), 0750); err != nil {
+		return nil, err
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rf.file, rf.size, rf.openedAt = file, info.Size(), time.Now()
+	return nil
+}
+
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.size > 0 && rf.needsRotation(int64(len(p))) {
+		rf.rotate()
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *rotatingFile) needsRotation(next int64) bool {
+	return (rf.maxSize > 0 && rf.size+next > rf.maxSize) ||
+		(rf.maxAge > 0 && time.Since(rf.openedAt) >= rf.maxAge)
+}
+
+// rotate renames the current file and opens a new one. On failure the lines
+// keep going to the current file, losing them would be worse than a big file.
+func (rf *rotatingFile) rotate() {
+	ext := filepath.Ext(rf.path)
+	backup := strings.TrimSuffix(rf.path, ext) + "-" + time.Now().Format(backupTimeFormat) + ext
+
+	if err := rf.closeFile(); err != nil {
+		log.Printf("Error closing log file for rotation: %v", err)
+	}
+	if err := os.Rename(rf.path, backup); err != nil {
+		log.Printf("Error rotating log file: %v", err)
+		backup = ""
+	}
+	if err := rf.open(); err != nil {
+		log.Printf("Error reopening log file, logging to stderr: %v", err)
+		rf.file = os.Stderr
+	}
+
+	rf.mill.Add(1)
+	go func() {
+		defer rf.mill.Done()
+		rf.millMu.Lock()
+		defer rf.millMu.Unlock()
+
+		if backup != "" && rf.compress {
+			if err := compressFile(backup); err != nil {
+				log.Printf("Error compressing rotated log file: %v", err)
+			}
+		}
+		rf.prune()
+	}()
+}
+
+// prune removes the rotated files beyond maxBackups and older than retainFor
+func (rf *rotatingFile) prune() {
+	ext := filepath.Ext(rf.path)
+	prefix := strings.TrimSuffix(filepath.Base(rf.path), ext) + "-"
+	dir := filepath.Dir(rf.path)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Error listing rotated log files: %v", err)
+		return
+	}
+
+	var backups []os.DirEntry
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, prefix) &&
+			(strings.HasSuffix(name, ext) || strings.HasSuffix(name, ext+".gz")) {
+			backups = append(backups, entry)
+		}
+	}
+	// newest first
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name() > backups[j].Name() })
+
+	for i, backup := range backups {
+		remove := rf.maxBackups > 0 && i >= rf.maxBackups
+		if !remove && rf.retainFor > 0 {
+			if info, err := backup.Info(); err == nil && time.Since(info.ModTime()) > rf.retainFor {
+				remove = true
+			}
+		}
+		if remove {
+			if err := os.Remove(filepath.Join(dir, backup.Name())); err != nil {
+				log.Printf("Error removing rotated log file: %v", err)
+			}
+		}
+	}
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func (rf *rotatingFile) Sync() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.file.Sync()
+}
+
+// Close closes the file and waits for the rotated files being compressed
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	err := rf.closeFile()
+	rf.mu.Unlock()
+
+	rf.mill.Wait()
+	return err
+}
+
+// closeFile closes the current file, stderr is left open when the log file
+// couldn't be reopened after a rotation
+func (rf *rotatingFile) closeFile() error {
+	if rf.file == os.Stderr {
+		return nil
+	}
+	return rf.file.Close()
+}
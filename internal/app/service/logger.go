@@ -1,17 +1,33 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"log"
-
 	"os"
+	"sirius_future/internal/config"
+	"sirius_future/internal/redact"
+	"sirius_future/internal/requestctx"
+	"strings"
 
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/exp/slog"
 )
 
 type LoggerService interface {
 	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
 	Error(msg string, err error, args ...any)
 	Debug(msg string, args ...any)
+
+	// The Context variants add the request ID, user ID, route and trace ID
+	// of the request in ctx, so the line can be tied to the request
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, err error, args ...any)
+	DebugContext(ctx context.Context, msg string, args ...any)
 }
 
 type loggerService struct {
@@ -22,20 +38,140 @@ func NewLoggerService(log *slog.Logger) *loggerService {
 	return &loggerService{log: log}
 }
 
-func InitLogger() *slog.Logger {
+// Log sinks, each has its own level
+const (
+	LogSinkFile   = "file"
+	LogSinkStdout = "stdout"
+)
+
+// LogLevels reads and changes the levels of the log sinks at runtime
+type LogLevels interface {
+	Levels() map[string]string
+	SetLevel(sink string, level string) error
+}
+
+// LogSinks owns the outputs of the logger
+type LogSinks struct {
+	levels map[string]*slog.LevelVar
+	file   *rotatingFile
+}
+
+// InitLogger returns the logger writing to the configured sinks, personal
+// data is redacted by the policy. The sinks must be closed on shutdown to
+// flush the file.
+func InitLogger(cfg config.LogConfig, policy *redact.Policy) (*slog.Logger, *LogSinks) {
+	sinks := &LogSinks{levels: map[string]*slog.LevelVar{}}
+	var handlers []slog.Handler
+
+	if cfg.File != "" {
+		file, err := openRotatingFile(cfg)
+		if err != nil {
+			log.Fatalf("Error opening log file: %v", err)
+		}
+		sinks.file = file
+		handlers = append(handlers, sinks.handler(LogSinkFile, cfg.FileLevel, file))
+	}
+	if cfg.Stdout {
+		handlers = append(handlers, sinks.handler(LogSinkStdout, cfg.StdoutLevel, os.Stdout))
+	}
+
+	return slog.New(policy.Handler(fanoutHandler(handlers))), sinks
+}
+
+func (ls *LogSinks) handler(sink string, level string, w io.Writer) slog.Handler {
+	levelVar := &slog.LevelVar{}
+	if err := levelVar.UnmarshalText([]byte(level)); err != nil {
+		log.Fatalf("Error setting %s log level: %v", sink, err)
+	}
+	ls.levels[sink] = levelVar
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: levelVar})
+}
+
+// Levels returns the current level of every sink
+func (ls *LogSinks) Levels() map[string]string {
+	levels := make(map[string]string, len(ls.levels))
+	for sink, level := range ls.levels {
+		levels[sink] = strings.ToLower(level.Level().String())
+	}
+	return levels
+}
+
+// SetLevel changes the level of the sink, of all sinks when sink is empty
+func (ls *LogSinks) SetLevel(sink string, level string) error {
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("unknown log level %q, expected debug, info, warn or error", level)
+	}
 
-	logFile, err := os.OpenFile("log/sirius_future.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
-	if err != nil {
-		log.Fatalf("Error opening log file: %v", err)
+	if sink == "" {
+		for _, levelVar := range ls.levels {
+			levelVar.Set(parsed)
+		}
+		return nil
 	}
-	Logger := slog.New(slog.NewJSONHandler(logFile, nil))
-	return Logger
+
+	levelVar, ok := ls.levels[sink]
+	if !ok {
+		return fmt.Errorf("unknown log sink %q", sink)
+	}
+	levelVar.Set(parsed)
+	return nil
+}
+
+// Close flushes and closes the log file
+func (ls *LogSinks) Close() error {
+	if ls.file == nil {
+		return nil
+	}
+	return errors.Join(ls.file.Sync(), ls.file.Close())
+}
+
+// fanoutHandler passes every record to the sinks whose level enables it
+type fanoutHandler []slog.Handler
+
+func (fh fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range fh {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (fh fanoutHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, h := range fh {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (fh fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanoutHandler, len(fh))
+	for i, h := range fh {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (fh fanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make(fanoutHandler, len(fh))
+	for i, h := range fh {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
 }
 
 func (lg *loggerService) Info(msg string, args ...any) {
 	lg.log.Info(msg, args...)
 }
 
+func (lg *loggerService) Warn(msg string, args ...any) {
+	lg.log.Warn(msg, args...)
+}
+
 func (lg *loggerService) Error(msg string, err error, args ...any) {
 	lg.log.Error(msg, append(args, "error", err)...)
 }
@@ -43,3 +179,38 @@ func (lg *loggerService) Error(msg string, err error, args ...any) {
 func (lg *loggerService) Debug(msg string, args ...any) {
 	lg.log.Debug(msg, args...)
 }
+
+func (lg *loggerService) InfoContext(ctx context.Context, msg string, args ...any) {
+	lg.log.Info(msg, append(requestAttrs(ctx), args...)...)
+}
+
+func (lg *loggerService) WarnContext(ctx context.Context, msg string, args ...any) {
+	lg.log.Warn(msg, append(requestAttrs(ctx), args...)...)
+}
+
+func (lg *loggerService) ErrorContext(ctx context.Context, msg string, err error, args ...any) {
+	lg.log.Error(msg, append(append(requestAttrs(ctx), args...), "error", err)...)
+}
+
+func (lg *loggerService) DebugContext(ctx context.Context, msg string, args ...any) {
+	lg.log.Debug(msg, append(requestAttrs(ctx), args...)...)
+}
+
+// requestAttrs returns the attributes of the request in ctx, fields unknown
+// yet (e.g. the user before authentication) are left out
+func requestAttrs(ctx context.Context) []any {
+	var attrs []any
+	if id := requestctx.RequestID(ctx); id != "" {
+		attrs = append(attrs, "request_id", id)
+	}
+	if userID, ok := requestctx.UserID(ctx); ok {
+		attrs = append(attrs, "user_id", userID)
+	}
+	if route := requestctx.Route(ctx); route != "" {
+		attrs = append(attrs, "route", route)
+	}
+	if span := trace.SpanContextFromContext(ctx); span.HasTraceID() {
+		attrs = append(attrs, "trace_id", span.TraceID().String())
+	}
+	return attrs
+}
@@ -0,0 +1,116 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/handler"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/config"
+	"sirius_future/internal/redact"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	rawEmail = "ivan.petrov@mail.ru"
+	rawPhone = "+79991234567"
+)
+
+var rawNames = []string{"Ivan", "Sergeevich", "Petrov"}
+
+func testUser() *entity.User {
+	return &entity.User{
+		ID:         7,
+		Firstname:  rawNames[0],
+		Secondname: rawNames[1],
+		Lastname:   rawNames[2],
+		Email:      rawEmail,
+		Password:   "$2a$10$hash",
+		Phone:      rawPhone,
+		Role:       "parent",
+	}
+}
+
+// Personal data must not reach the log file, whether it is logged as an
+// entity, quoted in an error or sent in an error response
+func TestLogRedaction(t *testing.T) {
+	cfg := config.Default()
+	cfg.Log.File = filepath.Join(t.TempDir(), "app.log")
+	cfg.Log.FileLevel = "debug"
+
+	logger, sinks := service.InitLogger(cfg.Log, redact.NewPolicy(cfg.Redaction))
+	log := service.NewLoggerService(logger)
+	ctx := context.Background()
+
+	// the way the repository logs failed writes
+	user := testUser()
+	log.ErrorContext(ctx, "Error creating user", errors.New("UNIQUE constraint failed: users.email"), "user", user)
+	payment := &entity.Payment{ID: 3, UserID: user.ID, Amount: 100, Currency: "RUB", Status: entity.PaymentStatusPending, User: *user}
+	log.ErrorContext(ctx, "Error creating payment", errors.New("database is locked"), "payment", payment)
+	log.InfoContext(ctx, "Registering user", "email", rawEmail, "phone", rawPhone)
+
+	body := errorResponse(t, cfg, log)
+
+	if err := sinks.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(cfg.Log.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logged := string(data)
+
+	if !strings.Contains(logged, "Error creating user") || !strings.Contains(logged, "Error creating payment") || !strings.Contains(logged, "Request failed") {
+		t.Fatalf("log file misses the records:\n%s", logged)
+	}
+	for _, raw := range append([]string{rawEmail, rawPhone}, rawNames...) {
+		if strings.Contains(logged, raw) {
+			t.Errorf("log file contains %q:\n%s", raw, logged)
+		}
+		if strings.Contains(body, raw) {
+			t.Errorf("error response contains %q: %s", raw, body)
+		}
+	}
+}
+
+// errorResponse renders an error quoting the contact data of the user with
+// the error handler of the app, it is logged as well since it is a 500
+func errorResponse(t *testing.T, cfg config.Config, log service.LoggerService) string {
+	t.Helper()
+
+	app := fiber.New(fiber.Config{
+		ErrorHandler: redact.NewPolicy(cfg.Redaction).ErrorResponses(handler.ErrorHandler(log)),
+	})
+	app.Use(handler.RenderErrors)
+	app.Get("/fail", func(c *fiber.Ctx) error {
+		return errors.New("no user with email " + rawEmail + " and phone " + rawPhone)
+	})
+	app.Get("/invalid", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadRequest, "phone "+rawPhone+" of "+rawEmail+" is taken")
+	})
+
+	var body strings.Builder
+	for _, path := range []string{"/fail", "/invalid"} {
+		response, err := app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if response.StatusCode < fiber.StatusBadRequest {
+			t.Fatalf("%s answered %d: %s", path, response.StatusCode, data)
+		}
+		body.Write(data)
+	}
+	return body.String()
+}
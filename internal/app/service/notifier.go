@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"os"
+	"sirius_future/internal/config"
+	"sync"
+	"time"
+)
+
+// Notifier delivers messages to users. Real providers (SMTP, SMS gateway)
+// implement it in production, locally the outbox implementations are used.
+type Notifier interface {
+	SendEmail(to, subject, body string) error
+	SendSMS(to, text string) error
+}
+
+// OutboxMessage is a single message written by the local notifiers
+type OutboxMessage struct {
+	Channel string    `json:"channel"`
+	To      string    `json:"to"`
+	Subject string    `json:"subject,omitempty"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+type fileNotifier struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileNotifier appends every message as a JSON line to the outbox file
+func NewFileNotifier(cfg config.NotifierConfig) *fileNotifier {
+	return &fileNotifier{path: cfg.OutboxFile}
+}
+
+func (fn *fileNotifier) SendEmail(to, subject, body string) error {
+	return fn.write(OutboxMessage{Channel: "email", To: to, Subject: subject, Body: body, SentAt: time.Now()})
+}
+
+func (fn *fileNotifier) SendSMS(to, text string) error {
+	return fn.write(OutboxMessage{Channel: "sms", To: to, Body: text, SentAt: time.Now()})
+}
+
+func (fn *fileNotifier) write(msg OutboxMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+
+	file, err := os.OpenFile(fn.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// MemoryNotifier keeps sent messages in memory, handy for tests and local runs
+type MemoryNotifier struct {
+	mu       sync.Mutex
+	messages []OutboxMessage
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (mn *MemoryNotifier) SendEmail(to, subject, body string) error {
+	mn.add(OutboxMessage{Channel: "email", To: to, Subject: subject, Body: body, SentAt: time.Now()})
+	return nil
+}
+
+func (mn *MemoryNotifier) SendSMS(to, text string) error {
+	mn.add(OutboxMessage{Channel: "sms", To: to, Body: text, SentAt: time.Now()})
+	return nil
+}
+
+// Messages returns a copy of everything sent so far
+func (mn *MemoryNotifier) Messages() []OutboxMessage {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	return append([]OutboxMessage(nil), mn.messages...)
+}
+
+func (mn *MemoryNotifier) add(msg OutboxMessage) {
+	mn.mu.Lock()
+	defer mn.mu.Unlock()
+
+	mn.messages = append(mn.messages, msg)
+}
@@ -2,35 +2,82 @@ package service
 
 import (
 	"context"
+	"sirius_future/internal/config"
+	"sirius_future/internal/tracing"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
+// RedisService — кэш в Redis. Методы принимают контекст запроса,
+// по нему команды попадают в трассировку запроса.
 type RedisService struct {
 	client *redis.Client
 }
 
-var ctx = context.Background()
-
-func NewRedisService(addr string) *RedisService {
+func NewRedisService(cfg config.CacheConfig) *RedisService {
 	rdb := redis.NewClient(&redis.Options{
-		Addr: addr, // адрес Redis (например, "localhost:6379")
+		Addr:     cfg.RedisAddr, // адрес Redis (например, "localhost:6379")
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
 	})
+	rdb.AddHook(tracing.RedisHook{})
 	return &RedisService{client: rdb}
 }
 
 // Set устанавливает значение в кэш
-func (rs *RedisService) Set(key string, value interface{}, expiration time.Duration) error {
+func (rs *RedisService) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) error {
 	return rs.client.Set(ctx, key, value, expiration).Err()
 }
 
-// Get получает значение из кэша
-func (rs *RedisService) Get(key string) (string, error) {
-	return rs.client.Get(ctx, key).Result()
+// Get получает значение из кэша, для отсутствующего ключа возвращает ErrCacheMiss
+func (rs *RedisService) Get(ctx context.Context, key string) (string, error) {
+	value, err := rs.client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return "", ErrCacheMiss
+	}
+	return value, err
+}
+
+// incrScript увеличивает счётчик и задаёт время жизни в одной команде, поэтому
+// счётчик без времени жизни не остаётся даже при сбое между шагами. Время жизни
+// задаётся при первом увеличении (фиксированное окно) и у ключа, оставшегося без него.
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 or redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// Incr увеличивает счётчик и при первом увеличении задаёт время жизни ключа
+func (rs *RedisService) Incr(ctx context.Context, key string, expiration time.Duration) (int64, error) {
+	return incrScript.Run(ctx, rs.client, []string{key}, expiration.Milliseconds()).Int64()
+}
+
+// TTL возвращает оставшееся время жизни ключа, 0 если ключа нет
+func (rs *RedisService) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := rs.client.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
 }
 
 // Delete удаляет значение из кэша
-func (rs *RedisService) Delete(key string) error {
+func (rs *RedisService) Delete(ctx context.Context, key string) error {
 	return rs.client.Del(ctx, key).Err()
 }
+
+// Ping проверяет доступность Redis
+func (rs *RedisService) Ping(ctx context.Context) error {
+	return rs.client.Ping(ctx).Err()
+}
+
+// Close закрывает соединения с Redis
+func (rs *RedisService) Close() error {
+	return rs.client.Close()
+}
@@ -0,0 +1,55 @@
+package service_test
+
+import (
+	"context"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/config"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// Lockout and rate limit counters must always expire, a counter left without
+// a TTL would lock the account until an admin unlocks it
+func TestRedisIncrSetsTTL(t *testing.T) {
+	server := miniredis.RunT(t)
+	redis := service.NewRedisService(config.CacheConfig{RedisAddr: server.Addr()})
+	defer redis.Close()
+	ctx := context.Background()
+
+	for want := int64(1); want <= 3; want++ {
+		count, err := redis.Incr(ctx, "login_failures", time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("Incr() = %d, want %d", count, want)
+		}
+		server.FastForward(10 * time.Second)
+	}
+	// the window is fixed, later increments don't extend it
+	if ttl := server.TTL("login_failures"); ttl != 30*time.Second {
+		t.Errorf("TTL after three increments = %s, want 30s", ttl)
+	}
+
+	// a counter left without a TTL gets one on the next increment
+	server.Set("stuck", "5")
+	count, err := redis.Incr(ctx, "stuck", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ttl := server.TTL("stuck"); count != 6 || ttl != time.Minute {
+		t.Errorf("Incr() of a counter without TTL = %d with TTL %s, want 6 with 1m", count, ttl)
+	}
+
+	server.FastForward(time.Minute)
+	if count, err := redis.Incr(ctx, "stuck", time.Minute); err != nil || count != 1 {
+		t.Errorf("Incr() after expiration = %d, %v, want 1", count, err)
+	}
+
+	server.Close()
+	if _, err := redis.Incr(ctx, "login_failures", time.Minute); err == nil {
+		t.Error("Incr() with Redis down returned no error")
+	}
+}
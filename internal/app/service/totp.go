@@ -0,0 +1,192 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"sirius_future/internal/config"
+	"strings"
+	"time"
+)
+
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // accepted steps before and after the current one
+)
+
+var ErrUnknownEncryptionKey = errors.New("secret is encrypted with an unknown key")
+
+// TOTPService implements RFC 6238 one-time passwords. Secrets are kept
+// encrypted at rest, only EncryptSecret output is stored in the database.
+type TOTPService interface {
+	NewSecret() (string, error)
+	ProvisioningURI(account string, secret string) string
+	Validate(secret string, code string, lastStep int64) (int64, bool)
+	GenerateRecoveryCodes(count int) ([]string, error)
+
+	EncryptSecret(secret string) (string, error)
+	DecryptSecret(encrypted string) (string, error)
+}
+
+type totpService struct {
+	issuer      string
+	keys        map[string]cipher.AEAD
+	activeKeyID string
+}
+
+// NewTOTPService takes the AES-256 keys by key id. New secrets are encrypted
+// with the active key, the old keys are kept to decrypt secrets written before
+// a key rotation.
+func NewTOTPService(cfg config.TOTPConfig) (*totpService, error) {
+	keys, err := cfg.Keys()
+	if err != nil {
+		return nil, err
+	}
+	activeKeyID := cfg.EncryptionKeyID
+	ts := &totpService{issuer: cfg.Issuer, keys: map[string]cipher.AEAD{}, activeKeyID: activeKeyID}
+
+	for id, key := range keys {
+		if len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %q must be 32 bytes, got %d", id, len(key))
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		ts.keys[id] = aead
+	}
+
+	if _, ok := ts.keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("active encryption key %q is not configured", activeKeyID)
+	}
+	return ts, nil
+}
+
+func (ts *totpService) NewSecret() (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(buf), nil
+}
+
+func (ts *totpService) ProvisioningURI(account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", ts.issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(ts.issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+// Validate checks the code against the current time step and its neighbours.
+// Steps up to lastStep were already used and are rejected to prevent replays.
+// On success the matched step is returned, it must be stored as the new lastStep.
+func (ts *totpService) Validate(secret string, code string, lastStep int64) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns codes like "k7qm-2xpa-rt4n", see NormalizeRecoveryCode
+func (ts *totpService) GenerateRecoveryCodes(count int) ([]string, error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	codes := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(encoding.EncodeToString(buf))[:12]
+		codes = append(codes, code[:4]+"-"+code[4:8]+"-"+code[8:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode drops separators and case, so users may type the code as they like
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func totpCode(key []byte, step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// EncryptSecret returns "<key id>:<base64 nonce+ciphertext>"
+func (ts *totpService) EncryptSecret(secret string) (string, error) {
+	aead := ts.keys[ts.activeKeyID]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(secret), []byte(ts.activeKeyID))
+	return ts.activeKeyID + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (ts *totpService) DecryptSecret(encrypted string) (string, error) {
+	keyID, payload, found := strings.Cut(encrypted, ":")
+	if !found {
+		return "", ErrUnknownEncryptionKey
+	}
+
+	aead, ok := ts.keys[keyID]
+	if !ok {
+		return "", ErrUnknownEncryptionKey
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+
+	plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
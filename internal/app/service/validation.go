@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"sirius_future/internal/app/entity"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Rules checked against the database, the field holds a user id:
+//
+//	UserID uint `json:"user_id" validate:"required,user_exists"`
+const RuleUserExists = "user_exists"
+
+func init() {
+	rules := []struct {
+		tag   string
+		rule  validator.FuncCtx
+		texts map[string]string
+	}{
+		{RuleUserExists, userExists, map[string]string{
+			entity.LanguageEnglish: "{0} must refer to an existing user",
+			entity.LanguageRussian: "{0} должен ссылаться на существующего пользователя",
+		}},
+	}
+	for _, rule := range rules {
+		if err := entity.RegisterRule(rule.tag, rule.rule, rule.texts); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// validation is passed to the rules in the context, rules can only report
+// true or false so a database error is kept here
+type validation struct {
+	fss *futureSiriusService
+	err error
+}
+
+type validationKey struct{}
+
+// ValidateRequest normalizes the request when it has a Normalize method and
+// checks it against its validate tags, including the database rules
+func (fss *futureSiriusService) ValidateRequest(ctx context.Context, request any) error {
+	if normalizer, ok := request.(interface{ Normalize() }); ok {
+		normalizer.Normalize()
+	}
+
+	state := &validation{fss: fss}
+	err := entity.ValidateRequest(context.WithValue(ctx, validationKey{}, state), request)
+	if state.err != nil {
+		return state.err
+	}
+	return err
+}
+
+func userExists(ctx context.Context, field validator.FieldLevel) bool {
+	state, ok := ctx.Value(validationKey{}).(*validation)
+	if !ok || !field.Field().CanUint() {
+		return false
+	}
+
+	var count int64
+	if err := state.fss.db.WithContext(ctx).Model(&entity.User{}).Where("id = ?", field.Field().Uint()).Count(&count).Error; err != nil {
+		state.err = err
+		return true
+	}
+	return count > 0
+}
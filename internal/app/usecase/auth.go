@@ -0,0 +1,219 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/tracing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	passwordResetTTL = 30 * time.Minute
+
+	forgotPasswordWindow     = time.Hour
+	forgotPasswordPerAccount = 3
+	forgotPasswordPerIP      = 20
+
+	// dummyPasswordHash is a bcrypt hash of a random password with the default cost
+	dummyPasswordHash = "$2a$10$b2b4DiMGvbPKO6VuJ1bf..RjkLsGXMFchKjlYeoKT.Udkd4wr0g/S"
+)
+
+// LoginResult holds either the access token or, when the user has two-factor
+// authentication enabled, the mfa token to pass to LoginMFA with the code
+type LoginResult struct {
+	Token       string
+	MFARequired bool
+	MFAToken    string
+}
+
+func (fru *futureSiriusUsecase) Login(ctx context.Context, email string, password string, ip string) (*LoginResult, error) {
+	ctx, span := tracing.Start(ctx, "usecase.Login")
+	defer span.End()
+
+	email = entity.NormalizeEmail(email)
+
+	if err := fru.checkLogin(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
+	user, err := fru.repo.GetUserByEmail(ctx, email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// unknown emails and legacy accounts without a password are checked against
+	// a dummy hash, the response time must not tell whether the account exists
+	passwordHash := dummyPasswordHash
+	if err == nil && user.Password != "" {
+		passwordHash = user.Password
+	}
+	if !fru.auth.CheckPassword(passwordHash, password) || passwordHash == dummyPasswordHash {
+		fru.loginFailed(ctx, email, ip, "password")
+		return nil, entity.ErrInvalidCredentials
+	}
+
+	if user.Status != entity.UserStatusActive {
+		return nil, entity.ErrUserNotActive
+	}
+
+	if user.TOTPEnabled {
+		mfaToken, err := fru.auth.GenerateMFAToken(user)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+
+	fru.loginSucceeded(ctx, email)
+
+	token, err := fru.auth.GenerateToken(user, false)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Token: token}, nil
+}
+
+// Authenticate checks the access token and that it was not revoked
+func (fru *futureSiriusUsecase) Authenticate(ctx context.Context, token string) (*service.TokenClaims, error) {
+	ctx, span := tracing.Start(ctx, "usecase.Authenticate")
+	defer span.End()
+
+	claims, err := fru.auth.ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Purpose != service.TokenPurposeAccess {
+		return nil, service.ErrInvalidToken
+	}
+
+	session, err := fru.userSession(ctx, claims.UserID)
+	if err != nil {
+		return nil, service.ErrInvalidToken
+	}
+
+	if session.TokenVersion != claims.TokenVersion || session.Status != entity.UserStatusActive {
+		return nil, service.ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// userSession is the part of the user Authenticate checks
+type userSession struct {
+	TokenVersion uint   `json:"token_version"`
+	Status       string `json:"status"`
+}
+
+// userSession is cached per user, the writes that revoke tokens or change the
+// status invalidate the tag of the user
+func (fru *futureSiriusUsecase) userSession(ctx context.Context, id uint) (*userSession, error) {
+	cacheKey := fru.cacheKey(ctx, entityCacheName("session", id), tagUser(id))
+
+	return loadCached(ctx, fru, "session", cacheKey, func(ctx context.Context) (*userSession, error) {
+		user, err := fru.repo.GetUserByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return &userSession{TokenVersion: user.TokenVersion, Status: user.Status}, nil
+	})
+}
+
+// ForgotPassword sends a reset token if the account exists. The result is the
+// same whether the email is registered or not, only rate limits are reported.
+func (fru *futureSiriusUsecase) ForgotPassword(ctx context.Context, email string, ip string) error {
+	ctx, span := tracing.Start(ctx, "usecase.ForgotPassword")
+	defer span.End()
+
+	email = entity.NormalizeEmail(email)
+
+	if !fru.allowRequest(ctx, "forgot_password_ip_"+ip, forgotPasswordPerIP, forgotPasswordWindow) ||
+		!fru.allowRequest(ctx, "forgot_password_account_"+email, forgotPasswordPerAccount, forgotPasswordWindow) {
+		return entity.ErrTooManyRequests
+	}
+
+	user, err := fru.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := fru.repo.InvalidateVerifications(ctx, user.ID, entity.VerificationChannelPasswordReset); err != nil {
+		return err
+	}
+
+	token, err := fru.service.GenerateVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	verification := &entity.Verification{
+		UserID:    user.ID,
+		Channel:   entity.VerificationChannelPasswordReset,
+		CodeHash:  fru.service.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := fru.repo.CreateVerification(ctx, verification); err != nil {
+		return err
+	}
+
+	// a failed delivery is not reported, the response would tell that the account exists
+	if err := fru.notifier.SendEmail(user.Email, "Восстановление пароля", fmt.Sprintf("Ваш код для сброса пароля: %s", token)); err != nil {
+		fru.log.ErrorContext(ctx, "Error sending password reset", err, "userID", user.ID)
+	}
+
+	return nil
+}
+
+// ResetPassword consumes the reset token, sets the new password and revokes all sessions
+func (fru *futureSiriusUsecase) ResetPassword(ctx context.Context, token string, password string) error {
+	ctx, span := tracing.Start(ctx, "usecase.ResetPassword")
+	defer span.End()
+
+	if err := entity.ValidatePassword(password); err != nil {
+		return entity.ErrInvalidPassword
+	}
+
+	verification, err := fru.repo.GetVerificationByHash(ctx, entity.VerificationChannelPasswordReset, fru.service.HashToken(token))
+	if err != nil {
+		return err
+	}
+
+	passwordHash, err := fru.auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	used, err := fru.repo.UseVerification(ctx, verification.ID)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return entity.ErrVerificationInvalid
+	}
+
+	if err := fru.repo.ResetUserPassword(ctx, verification.UserID, passwordHash); err != nil {
+		return err
+	}
+
+	fru.invalidate(ctx, tagUsers, tagUser(verification.UserID))
+
+	return nil
+}
+
+// allowRequest counts requests per key in a fixed window. When the cache is not
+// available the request is allowed, the limiter must not take the api down.
+func (fru *futureSiriusUsecase) allowRequest(ctx context.Context, key string, limit int64, window time.Duration) bool {
+	count, err := fru.cache.Incr(ctx, key, window)
+	if err != nil {
+		return true
+	}
+	return count <= limit
+}
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/tracing"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	cacheTTL        = 3 * time.Hour
+	cacheVersionTTL = 24 * time.Hour
+	cacheJitter     = 0.1 // expirations are spread by ±10% so keys don't expire together
+
+	tagUsers    = "users"
+	tagLinks    = "links"
+	tagPayments = "payments"
+)
+
+var cacheRequestsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Total number of cache lookups by key family and result (hit, miss, stale)",
+	},
+	[]string{"family", "result"},
+)
+
+// cachePolicy sets the expiration of a key family. After softTTL the value is
+// still served but refreshed in the background (stale-while-revalidate), zero
+// disables it. After ttl the value is gone and the next read waits for the database.
+type cachePolicy struct {
+	ttl     time.Duration
+	softTTL time.Duration
+}
+
+// Sessions are checked on every request and must follow a revocation, they
+// are never served stale and expire quickly: while Redis is down an
+// invalidation only reaches the local cache of one instance.
+var cachePolicies = map[string]cachePolicy{
+	"session":       {ttl: time.Minute},
+	"users":         {ttl: cacheTTL, softTTL: 10 * time.Minute},
+	"links":         {ttl: cacheTTL, softTTL: 10 * time.Minute},
+	"payments":      {ttl: cacheTTL, softTTL: 5 * time.Minute},
+	"user_payments": {ttl: cacheTTL, softTTL: 5 * time.Minute},
+}
+
+type cacheEnvelope[T any] struct {
+	Value         T     `json:"v"`
+	SoftExpiresAt int64 `json:"s,omitempty"` // unix nano, zero when the family has no soft TTL
+}
+
+// loadCached returns the value cached under key or loads it. Concurrent misses
+// of the same key are coalesced into one load, stale values are returned
+// immediately while one background load refreshes them. The load is shared by
+// several requests or outlives the request, so it isn't canceled with ctx.
+func loadCached[T any](ctx context.Context, fsu *futureSiriusUsecase, family string, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	policy, ok := cachePolicies[family]
+	if !ok {
+		policy = cachePolicy{ttl: cacheTTL}
+	}
+
+	loadCtx := context.WithoutCancel(ctx)
+	refresh := func() (interface{}, error) {
+		value, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+		fsu.storeCached(loadCtx, key, policy, value)
+		return value, nil
+	}
+
+	var envelope cacheEnvelope[T]
+	if fsu.getCached(ctx, key, &envelope) {
+		if envelope.SoftExpiresAt == 0 || time.Now().UnixNano() < envelope.SoftExpiresAt {
+			cacheRequestsTotal.WithLabelValues(family, "hit").Inc()
+			return envelope.Value, nil
+		}
+
+		cacheRequestsTotal.WithLabelValues(family, "stale").Inc()
+		fsu.goBackground(func() { fsu.flight.Do(key, refresh) })
+		return envelope.Value, nil
+	}
+
+	cacheRequestsTotal.WithLabelValues(family, "miss").Inc()
+	value, err, _ := fsu.flight.Do(key, refresh)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return value.(T), nil
+}
+
+func (fsu *futureSiriusUsecase) storeCached(ctx context.Context, key string, policy cachePolicy, value interface{}) {
+	envelope := cacheEnvelope[interface{}]{Value: value}
+	if policy.softTTL > 0 {
+		envelope.SoftExpiresAt = time.Now().Add(jitter(policy.softTTL)).UnixNano()
+	}
+
+	data, err := json.Marshal(envelope)
+	if err == nil {
+		fsu.cache.Set(ctx, key, data, jitter(policy.ttl))
+	}
+}
+
+func jitter(ttl time.Duration) time.Duration {
+	spread := time.Duration(float64(ttl) * cacheJitter)
+	if spread <= 0 {
+		return ttl
+	}
+	return ttl - spread + rand.N(2*spread)
+}
+
+// listCacheName identifies one list query: the family with its filter and page
+// request, so every page and filter combination is cached on its own
+func listCacheName(family string, filter interface{}, page entity.PageRequest) string {
+	query, _ := json.Marshal(struct {
+		Filter interface{}        `json:"f"`
+		Page   entity.PageRequest `json:"p"`
+	}{filter, page})
+
+	hash := sha256.Sum256(query)
+	return family + ":" + hex.EncodeToString(hash[:16])
+}
+
+// entityCacheName identifies one entity of the family
+func entityCacheName(family string, id uint) string {
+	return fmt.Sprintf("%s:%d", family, id)
+}
+
+// tagUser covers everything cached with the data of one user
+func tagUser(id uint) string {
+	return fmt.Sprintf("user:%d", id)
+}
+
+// tagUserPayments covers the payment lists of one user
+func tagUserPayments(id uint) string {
+	return fmt.Sprintf("user_payments:%d", id)
+}
+
+// cacheKey builds the key of a cached value from the current versions of the
+// tags it depends on. Invalidating a tag replaces its version, so keys built
+// before are never read again and simply expire. The key must be built before
+// reading the database: if a write happens in between, the value is stored
+// under the old version and can't be served stale.
+func (fsu *futureSiriusUsecase) cacheKey(ctx context.Context, name string, tags ...string) string {
+	var key strings.Builder
+	key.WriteString(name)
+	for _, tag := range tags {
+		key.WriteString("|")
+		key.WriteString(tag)
+		key.WriteString("@")
+		key.WriteString(fsu.tagVersion(ctx, tag))
+	}
+	return key.String()
+}
+
+// tagVersion returns the version of the tag and creates it when missing.
+// Versions are timestamps, not counters, so a lost version key never brings
+// back values cached under an older version.
+func (fsu *futureSiriusUsecase) tagVersion(ctx context.Context, tag string) string {
+	key := "cache_version:" + tag
+
+	version, err := fsu.cache.Get(ctx, key)
+	if err == nil && version != "" {
+		return version
+	}
+
+	version = newTagVersion()
+	fsu.cache.Set(ctx, key, version, cacheVersionTTL)
+	return version
+}
+
+// invalidate drops every cached value depending on the tags, one cache write per tag
+func (fsu *futureSiriusUsecase) invalidate(ctx context.Context, tags ...string) {
+	for _, tag := range tags {
+		fsu.cache.Set(ctx, "cache_version:"+tag, newTagVersion(), cacheVersionTTL)
+	}
+}
+
+func newTagVersion() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+func (fsu *futureSiriusUsecase) getCached(ctx context.Context, key string, dest interface{}) bool {
+	data, err := fsu.cache.Get(ctx, key)
+	if err != nil || data == "" {
+		return false
+	}
+
+	_, span := tracing.Start(ctx, "cache.decode", attribute.Int("cache.value_size", len(data)))
+	defer span.End()
+	if err := json.Unmarshal([]byte(data), dest); err != nil {
+		tracing.RecordError(span, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,131 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// Concurrent misses of one key wait for a single load
+func TestLoadCachedCoalescesMisses(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+
+	var loads atomic.Int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		<-release
+		return "value", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := loadCached(ctx, tu.futureSiriusUsecase, "users", "coalesced", load)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = value
+		}()
+	}
+	// let every request reach the cache before the load returns, the late
+	// ones read the stored value
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if got := loads.Load(); got != 1 {
+		t.Errorf("loaded %d times, want 1", got)
+	}
+	for i, value := range results {
+		if value != "value" {
+			t.Errorf("request %d got %q", i, value)
+		}
+	}
+}
+
+// A value past its soft TTL is served at once and refreshed in the background
+func TestLoadCachedRefreshesStaleValue(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+
+	stale, err := json.Marshal(cacheEnvelope[string]{Value: "stale", SoftExpiresAt: time.Now().Add(-time.Second).UnixNano()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.cache.Set(ctx, "stale", stale, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	var loads atomic.Int32
+	load := func(ctx context.Context) (string, error) {
+		loads.Add(1)
+		return "fresh", nil
+	}
+
+	value, err := loadCached(ctx, tu.futureSiriusUsecase, "payments", "stale", load)
+	if err != nil || value != "stale" {
+		t.Fatalf("loadCached() = %q, %v, want the stale value", value, err)
+	}
+	// waits for the refresh
+	if err := tu.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err = loadCached(ctx, tu.futureSiriusUsecase, "payments", "stale", load)
+	if err != nil || value != "fresh" {
+		t.Errorf("loadCached() after the refresh = %q, %v, want the fresh value", value, err)
+	}
+	if got := loads.Load(); got != 1 {
+		t.Errorf("loaded %d times, want 1", got)
+	}
+}
+
+// A failed load is not cached, the next request loads again
+func TestLoadCachedDoesNotCacheErrors(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+
+	failing := func(ctx context.Context) (string, error) {
+		return "", context.DeadlineExceeded
+	}
+	if _, err := loadCached(ctx, tu.futureSiriusUsecase, "users", "failing", failing); err != context.DeadlineExceeded {
+		t.Fatalf("loadCached() = %v, want the load error", err)
+	}
+
+	value, err := loadCached(ctx, tu.futureSiriusUsecase, "users", "failing", func(ctx context.Context) (string, error) {
+		return "value", nil
+	})
+	if err != nil || value != "value" {
+		t.Errorf("loadCached() after a failed load = %q, %v", value, err)
+	}
+}
+
+// Invalidating a tag changes the keys built from it, other tags keep theirs
+func TestInvalidate(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+
+	users := tu.cacheKey(ctx, "users:list", tagUsers)
+	user := tu.cacheKey(ctx, "session:1", tagUser(1))
+	if again := tu.cacheKey(ctx, "users:list", tagUsers); again != users {
+		t.Fatalf("cacheKey() changed without an invalidation: %q, %q", users, again)
+	}
+
+	time.Sleep(time.Millisecond) // versions are timestamps
+	tu.invalidate(ctx, tagUsers)
+
+	if tu.cacheKey(ctx, "users:list", tagUsers) == users {
+		t.Error("cacheKey() is the same after the tag was invalidated")
+	}
+	if tu.cacheKey(ctx, "session:1", tagUser(1)) != user {
+		t.Error("invalidating a tag changed the key of another tag")
+	}
+}
@@ -1,171 +1,224 @@
 package usecase
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"regexp"
 	"sirius_future/internal/app/entity"
 	"sirius_future/internal/app/repository"
 	"sirius_future/internal/app/service"
-	"time"
+	"sirius_future/internal/tracing"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
 )
 
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
 type FutureSiriusUsecase interface {
-	CreateLink(userID uint, limit uint) (string, error)
-	CheckTheLink(url string) (bool, error)
-	CreateUser(user *entity.User) error
-
-	GetReferrerByUrl(url string) (*entity.User, error)
-	GetAllLinks() ([]entity.Link, error)
-	GetAllUsers() ([]entity.User, error)
-
-	CreatePayment(payment *entity.Payment) error
-	GetAllPayments() ([]entity.Payment, error)
-	GetPaymentsByUserID(id uint) ([]entity.Payment, error)
-	UpdatePayment(id uint, payment *entity.Payment) error
+	CreateLink(ctx context.Context, userID uint, limit uint) (string, error)
+	CheckTheLink(ctx context.Context, url string) (bool, error)
+	CreateUser(ctx context.Context, user *entity.User) error
+
+	GetReferrerByUrl(ctx context.Context, url string) (*entity.User, error)
+	ListLinks(ctx context.Context, filter entity.LinkFilter, page entity.PageRequest) (*entity.Page[entity.Link], error)
+	ListUsers(ctx context.Context, filter entity.UserFilter, page entity.PageRequest) (*entity.Page[entity.User], error)
+
+	CreatePayment(ctx context.Context, payment *entity.Payment) error
+	ListPayments(ctx context.Context, filter entity.PaymentFilter, page entity.PageRequest) (*entity.Page[entity.Payment], error)
+	UpdatePayment(ctx context.Context, id uint, payment *entity.Payment) error
+
+	VerifyEmail(ctx context.Context, token string) (*entity.User, error)
+	VerifyPhone(ctx context.Context, userID uint, code string) (*entity.User, error)
+	ResendVerification(ctx context.Context, userID uint, ip string) error
+
+	Login(ctx context.Context, email string, password string, ip string) (*LoginResult, error)
+	LoginMFA(ctx context.Context, mfaToken string, code string, ip string) (string, error)
+	UnlockLogin(ctx context.Context, email string, ip string) error
+	Authenticate(ctx context.Context, token string) (*service.TokenClaims, error)
+	RequiresMFA(role string) bool
+	ForgotPassword(ctx context.Context, email string, ip string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+
+	EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error)
+
+	Shutdown(ctx context.Context) error
 }
 
 type futureSiriusUsecase struct {
-	repo    repository.FutureSiriusRepository
-	service service.FutureSiriusService
-	redis   service.RedisService
+	repo     repository.FutureSiriusRepository
+	service  service.FutureSiriusService
+	cache    service.Cache
+	notifier service.Notifier
+	auth     service.AuthService
+	totp     service.TOTPService
+	log      service.LoggerService
+
+	flight singleflight.Group // coalesces concurrent cache misses, see loadCached
+
+	// background tracks work started outside of requests, e.g. cache refreshes
+	background   sync.WaitGroup
+	backgroundMu sync.Mutex
+	stopped      bool
 }
 
-func NewFutureSiriusUsecase(repo repository.FutureSiriusRepository, service service.FutureSiriusService, redis service.RedisService) *futureSiriusUsecase {
-	return &futureSiriusUsecase{repo: repo, service: service, redis: redis}
+func NewFutureSiriusUsecase(repo repository.FutureSiriusRepository, service service.FutureSiriusService, cache service.Cache, notifier service.Notifier, auth service.AuthService, totp service.TOTPService, log service.LoggerService) *futureSiriusUsecase {
+	return &futureSiriusUsecase{repo: repo, service: service, cache: cache, notifier: notifier, auth: auth, totp: totp, log: log}
 }
-func (fsu *futureSiriusUsecase) GetPaymentsByUserID(userID uint) ([]entity.Payment, error) {
-	cacheKey := fmt.Sprintf("user_payments_%d", userID)
 
-	cachedData, err := fsu.redis.Get(cacheKey)
-	if err == nil && cachedData != "" {
-		var payments []entity.Payment
-		err = json.Unmarshal([]byte(cachedData), &payments)
-		if err == nil {
-			return payments, nil
-		}
-	}
+// goBackground runs fn in a goroutine unless the usecase is shutting down
+func (fsu *futureSiriusUsecase) goBackground(fn func()) {
+	fsu.backgroundMu.Lock()
+	defer fsu.backgroundMu.Unlock()
 
-	payments, err := fsu.repo.GetPaymentsByUserID(userID)
-	if err != nil {
-		return nil, err
+	if fsu.stopped {
+		return
 	}
+	fsu.background.Add(1)
+	go func() {
+		defer fsu.background.Done()
+		fn()
+	}()
+}
 
-	data, err := json.Marshal(payments)
-	if err == nil {
-		fsu.redis.Set(cacheKey, data, 3*time.Hour)
+// Shutdown stops starting background work and waits for the running work
+// until ctx is done
+func (fsu *futureSiriusUsecase) Shutdown(ctx context.Context) error {
+	fsu.backgroundMu.Lock()
+	fsu.stopped = true
+	fsu.backgroundMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		fsu.background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-
-	return payments, nil
 }
-
-func (fsu *futureSiriusUsecase) GetAllPayments() ([]entity.Payment, error) {
-	data, err := fsu.redis.Get("all_payments")
-	if err == nil && data != "" {
-		var payments []entity.Payment
-		err = json.Unmarshal([]byte(data), &payments)
-		if err == nil {
-			return payments, nil
-		}
+func (fsu *futureSiriusUsecase) ListPayments(ctx context.Context, filter entity.PaymentFilter, page entity.PageRequest) (*entity.Page[entity.Payment], error) {
+	ctx, span := tracing.Start(ctx, "usecase.ListPayments")
+	defer span.End()
+
+	// lists of one user don't depend on the payments of everybody else,
+	// payments don't embed their user so user changes don't affect them
+	tags := []string{tagPayments}
+	family := "payments"
+	if filter.UserID != nil {
+		tags = []string{tagUserPayments(*filter.UserID)}
+		family = "user_payments"
 	}
 
-	pAyments, err := fsu.repo.GetAllPayments()
-	if err != nil {
-		return nil, err
-	}
+	cacheKey := fsu.cacheKey(ctx, listCacheName(family, filter, page), tags...)
 
-	result, err := json.Marshal(pAyments)
-	if err == nil {
-		fsu.redis.Set("all_payments", result, 3*time.Hour)
-	}
+	return loadCached(ctx, fsu, family, cacheKey, func(ctx context.Context) (*entity.Page[entity.Payment], error) {
+		return fsu.repo.ListPayments(ctx, filter, page)
+	})
+}
 
-	return pAyments, nil
+func (fsu *futureSiriusUsecase) CreatePayment(ctx context.Context, payment *entity.Payment) error {
+	ctx, span := tracing.Start(ctx, "usecase.CreatePayment")
+	defer span.End()
 
-}
-func (fsu *futureSiriusUsecase) CreatePayment(payment *entity.Payment) error {
-	if err := fsu.repo.CreatePayment(payment); err != nil {
+	if err := normalizePayment(payment); err != nil {
 		return err
 	}
+	if payment.Status == "" {
+		payment.Status = entity.PaymentStatusPending
+	}
+	if payment.Currency == "" {
+		payment.Currency = entity.DefaultCurrency
+	}
 
-	payments, _ := fsu.repo.GetAllPayments()
-
-	data, err := json.Marshal(payments)
-	if err == nil {
-		fsu.redis.Set("all_payments", data, 3*time.Hour)
+	if err := fsu.repo.CreatePayment(ctx, payment); err != nil {
+		return err
 	}
 
+	fsu.invalidate(ctx, tagPayments, tagUserPayments(payment.UserID))
+	recordPaymentCreated(payment)
+
 	return nil
 }
 
-func (fsu *futureSiriusUsecase) UpdatePayment(id uint, payment *entity.Payment) error {
-	if err := fsu.repo.UpdatePayment(id, payment); err != nil {
+func (fsu *futureSiriusUsecase) UpdatePayment(ctx context.Context, id uint, payment *entity.Payment) error {
+	ctx, span := tracing.Start(ctx, "usecase.UpdatePayment")
+	defer span.End()
+
+	if err := normalizePayment(payment); err != nil {
 		return err
 	}
 
-	payments, _ := fsu.repo.GetAllPayments()
-
-	data, err := json.Marshal(payments)
-	if err == nil {
-		fsu.redis.Set("all_payments", data, 3*time.Hour)
+	previous, updated, err := fsu.repo.UpdatePayment(ctx, id, payment)
+	if err != nil {
+		return err
 	}
 
+	fsu.invalidate(ctx, tagPayments, tagUserPayments(updated.UserID))
+	recordPaymentUpdated(previous, updated)
+
 	return nil
 }
 
-func (fsu *futureSiriusUsecase) GetAllLinks() ([]entity.Link, error) {
-	cachedData, err := fsu.redis.Get("all_links")
-	if err == nil && cachedData != "" {
-		var links []entity.Link
-		err = json.Unmarshal([]byte(cachedData), &links)
-		if err == nil {
-			return links, nil
-		}
+// normalizePayment checks the status and the currency when they are set
+func normalizePayment(payment *entity.Payment) error {
+	if payment.Status != "" && !entity.IsKnownPaymentStatus(payment.Status) {
+		return entity.InvalidField("status", "must be one of pending, completed, failed or refunded, got %q", payment.Status)
 	}
 
-	links, err := fsu.repo.GetAllLinks()
-	if err != nil {
-		return nil, err
+	payment.Currency = strings.ToUpper(strings.TrimSpace(payment.Currency))
+	if payment.Currency != "" && !currencyCode.MatchString(payment.Currency) {
+		return entity.InvalidField("currency", "must be an ISO 4217 code, got %q", payment.Currency)
 	}
+	return nil
+}
 
-	data, err := json.Marshal(links)
-	if err == nil {
-		fsu.redis.Set("all_links", data, 3*time.Hour)
-	}
+func (fsu *futureSiriusUsecase) ListLinks(ctx context.Context, filter entity.LinkFilter, page entity.PageRequest) (*entity.Page[entity.Link], error) {
+	ctx, span := tracing.Start(ctx, "usecase.ListLinks")
+	defer span.End()
 
-	return links, nil
+	cacheKey := fsu.cacheKey(ctx, listCacheName("links", filter, page), tagLinks)
 
+	return loadCached(ctx, fsu, "links", cacheKey, func(ctx context.Context) (*entity.Page[entity.Link], error) {
+		return fsu.repo.ListLinks(ctx, filter, page)
+	})
 }
 
-func (fsu *futureSiriusUsecase) GetAllUsers() ([]entity.User, error) {
-	cachedData, err := fsu.redis.Get("all_users")
-	if err == nil && cachedData != "" {
-		var users []entity.User
-		err = json.Unmarshal([]byte(cachedData), &users)
-		if err == nil {
-			return users, nil
-		}
-	}
+func (fsu *futureSiriusUsecase) ListUsers(ctx context.Context, filter entity.UserFilter, page entity.PageRequest) (*entity.Page[entity.User], error) {
+	ctx, span := tracing.Start(ctx, "usecase.ListUsers")
+	defer span.End()
 
-	users, err := fsu.repo.GetAllUsers()
-	if err != nil {
-		return nil, err
-	}
+	cacheKey := fsu.cacheKey(ctx, listCacheName("users", filter, page), tagUsers)
 
-	data, err := json.Marshal(users)
-	if err == nil {
-		fsu.redis.Set("all_users", data, 3*time.Hour)
-	}
-
-	return users, nil
+	return loadCached(ctx, fsu, "users", cacheKey, func(ctx context.Context) (*entity.Page[entity.User], error) {
+		return fsu.repo.ListUsers(ctx, filter, page)
+	})
 }
-func (fsu *futureSiriusUsecase) GetReferrerByUrl(url string) (*entity.User, error) {
-	return fsu.repo.GetReferrerByUrl(url)
+func (fsu *futureSiriusUsecase) GetReferrerByUrl(ctx context.Context, url string) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetReferrerByUrl")
+	defer span.End()
+
+	return fsu.repo.GetReferrerByUrl(ctx, url)
 }
 
-func (fru *futureSiriusUsecase) CreateLink(userID uint, limit uint) (string, error) {
+func (fru *futureSiriusUsecase) CreateLink(ctx context.Context, userID uint, limit uint) (string, error) {
+	ctx, span := tracing.Start(ctx, "usecase.CreateLink")
+	defer span.End()
 
-	if err := fru.service.CheckUserByID(userID); err != nil {
+	// only active users share links, Authenticate refuses the tokens of the
+	// others already but the rule must hold for any caller
+	owner, err := fru.repo.GetUserByID(ctx, userID)
+	if err != nil {
 		return "", err
 	}
+	if owner.Status != entity.UserStatusActive {
+		return "", entity.ErrUserNotActive
+	}
 
 	url := fru.service.GenerateRefferalLink(userID)
 
@@ -174,41 +227,67 @@ func (fru *futureSiriusUsecase) CreateLink(userID uint, limit uint) (string, err
 		ReferrerID: userID,
 		Limit:      limit,
 	}
-	if err := fru.repo.CreateLink(Link); err != nil {
+	if err := fru.repo.CreateLink(ctx, Link); err != nil {
 		return "", err
 	}
 
-	links, _ := fru.repo.GetAllLinks()
-
-	data, err := json.Marshal(links)
-	if err == nil {
-		fru.redis.Set("all_links", data, 3*time.Hour)
-	}
+	fru.invalidate(ctx, tagLinks)
+	linksCreatedTotal.Inc()
 
 	return url, nil
 }
 
-func (fru *futureSiriusUsecase) CheckTheLink(url string) (bool, error) {
-	return fru.repo.CheckTheLink(url)
+func (fru *futureSiriusUsecase) CheckTheLink(ctx context.Context, url string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "usecase.CheckTheLink")
+	defer span.End()
+
+	outcome, err := fru.repo.CheckTheLink(ctx, url)
+	if err != nil {
+		return false, err
+	}
+	linkRedemptionsTotal.WithLabelValues(outcome).Inc()
+
+	if outcome != entity.LinkRedemptionSuccess {
+		return false, nil
+	}
+
+	fru.invalidate(ctx, tagLinks)
+	return true, nil
 }
 
-func (fru *futureSiriusUsecase) CreateUser(user *entity.User) error {
-	validateErrors := fru.service.UserValidate(user)
-	if len(validateErrors) > 0 {
-		for _, value := range validateErrors {
-			return fmt.Errorf("User Validate Error :%s", value)
-		}
+func (fru *futureSiriusUsecase) CreateUser(ctx context.Context, user *entity.User) error {
+	ctx, span := tracing.Start(ctx, "usecase.CreateUser")
+	defer span.End()
+
+	user.Normalize()
+
+	if err := fru.service.UserValidate(user); err != nil {
+		return err
+	}
+
+	passwordHash, err := fru.auth.HashPassword(user.Password)
+	if err != nil {
+		return err
 	}
+	user.Password = passwordHash
+
+	user.Status = entity.UserStatusPending
+	user.EmailVerifiedAt = nil
+	user.PhoneVerifiedAt = nil
 
-	if err := fru.repo.CreateUser(user); err != nil {
+	if err := fru.repo.CreateUser(ctx, user); err != nil {
 		return err
 	}
 
-	users, _ := fru.repo.GetAllUsers()
+	fru.invalidate(ctx, tagUsers)
+	if user.ReferrerID != 0 {
+		referralSignupsTotal.Inc()
+	}
 
-	data, err := json.Marshal(users)
-	if err == nil {
-		fru.redis.Set("all_users", data, 3*time.Hour)
+	// the account is created, a failed delivery is not reported to the client
+	// who can request new codes with /verify/resend
+	if err := fru.sendVerification(ctx, user, entity.VerificationChannelEmail, entity.VerificationChannelSMS); err != nil {
+		fru.log.ErrorContext(ctx, "Error sending verification", err, "userID", user.ID)
 	}
 
 	return nil
@@ -0,0 +1,207 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/migration"
+	"sirius_future/internal/app/repository"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/config"
+	"sirius_future/internal/redact"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testPassword = "secret-password"
+	testIP       = "203.0.113.7"
+)
+
+type testUsecase struct {
+	*futureSiriusUsecase
+	db       *gorm.DB
+	notifier *service.MemoryNotifier
+}
+
+// newTestUsecase wires the usecase like main does, with a migrated sqlite
+// database, the memory cache and the memory notifier
+func newTestUsecase(t *testing.T) *testUsecase {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator, err := migration.NewMigrator(db, config.DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Log.File = ""
+	cfg.Auth.JWTSecret = "test-secret"
+	cfg.TOTP.EncryptionKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	logger, _ := service.InitLogger(cfg.Log, redact.NewPolicy(cfg.Redaction))
+	log := service.NewLoggerService(logger)
+	totp, err := service.NewTOTPService(cfg.TOTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := service.NewMemoryNotifier()
+	fsu := NewFutureSiriusUsecase(
+		repository.NewFutureSiriusRepository(db, log),
+		service.NewFutureSiriusService(db),
+		service.NewMemoryCache(1000),
+		notifier,
+		service.NewAuthService(cfg.Auth),
+		totp,
+		log,
+	)
+	t.Cleanup(func() { fsu.Shutdown(context.Background()) })
+
+	return &testUsecase{futureSiriusUsecase: fsu, db: db, notifier: notifier}
+}
+
+// register creates a pending user, n makes the email and the phone unique
+func (tu *testUsecase) register(t *testing.T, n int, role string) *entity.User {
+	t.Helper()
+
+	user := &entity.User{
+		Firstname:  "Ivan",
+		Secondname: "Ivanovich",
+		Lastname:   "Ivanov",
+		Email:      fmt.Sprintf("user%d@mail.ru", n),
+		Password:   testPassword,
+		Phone:      fmt.Sprintf("+7999123%04d", n),
+		Role:       role,
+	}
+	if err := tu.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// activate confirms both contacts of the user with the codes sent last
+func (tu *testUsecase) activate(t *testing.T, user *entity.User) *entity.User {
+	t.Helper()
+	ctx := context.Background()
+
+	if _, err := tu.VerifyEmail(ctx, tu.lastCode(t, "email", user.Email)); err != nil {
+		t.Fatal(err)
+	}
+	activated, err := tu.VerifyPhone(ctx, user.ID, tu.lastCode(t, "sms", user.Phone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if activated.Status != entity.UserStatusActive {
+		t.Fatalf("user is %s after confirming both contacts", activated.Status)
+	}
+	return activated
+}
+
+// lastCode returns the code of the last message sent to the recipient, the
+// messages end with it
+func (tu *testUsecase) lastCode(t *testing.T, channel string, to string) string {
+	t.Helper()
+
+	messages := tu.notifier.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Channel == channel && messages[i].To == to {
+			fields := strings.Fields(messages[i].Body)
+			return fields[len(fields)-1]
+		}
+	}
+	t.Fatalf("no %s message sent to %s", channel, to)
+	return ""
+}
+
+// wrongCode returns a code of the same length that differs from code
+func wrongCode(code string) string {
+	return string('0'+(code[0]-'0'+1)%10) + code[1:]
+}
+
+type failingNotifier struct{}
+
+func (failingNotifier) SendEmail(to, subject, body string) error {
+	return errors.New("smtp is down")
+}
+
+func (failingNotifier) SendSMS(to, text string) error {
+	return errors.New("sms gateway is down")
+}
+
+// The account is created when the codes can't be delivered, they are requested again later
+func TestCreateUserWhenDeliveryFails(t *testing.T) {
+	tu := newTestUsecase(t)
+	tu.futureSiriusUsecase.notifier = failingNotifier{}
+
+	user := &entity.User{Firstname: "Ivan", Secondname: "Ivanovich", Lastname: "Ivanov", Email: " Ivan@Mail.RU ", Password: testPassword, Phone: "8 (999) 123-45-67", Role: "parent"}
+	if err := tu.CreateUser(context.Background(), user); err != nil {
+		t.Fatalf("CreateUser() = %v, want the user created", err)
+	}
+
+	stored, err := tu.repo.GetUserByEmail(context.Background(), "ivan@mail.ru")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Phone != "+79991234567" || stored.Status != entity.UserStatusPending || stored.Password == testPassword {
+		t.Errorf("stored user %+v", stored)
+	}
+}
+
+// Payment lists are invalidated by the writes of their own scope only
+func TestPaymentListInvalidation(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	first := tu.register(t, 1, "parent")
+	second := tu.register(t, 2, "parent")
+
+	count := func(userID *uint) int {
+		t.Helper()
+		page, err := tu.ListPayments(ctx, entity.PaymentFilter{UserID: userID}, entity.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(page.Items)
+	}
+	if count(nil) != 0 || count(&first.ID) != 0 {
+		t.Fatal("payments listed before any was created")
+	}
+
+	// written around the usecase, the cached lists don't see it
+	if err := tu.repo.CreatePayment(ctx, &entity.Payment{UserID: first.ID, Amount: 100, Description: "lesson", Currency: "RUB", Status: entity.PaymentStatusPending}); err != nil {
+		t.Fatal(err)
+	}
+	if count(nil) != 0 || count(&first.ID) != 0 {
+		t.Fatal("lists are not served from the cache")
+	}
+
+	if err := tu.CreatePayment(ctx, &entity.Payment{UserID: second.ID, Amount: 200, Description: "lesson"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(nil); got != 2 {
+		t.Errorf("list of all payments has %d items after a payment was created, want 2", got)
+	}
+	if got := count(&first.ID); got != 0 {
+		t.Errorf("a payment of another user invalidated the list of the user, got %d items", got)
+	}
+
+	if err := tu.CreatePayment(ctx, &entity.Payment{UserID: first.ID, Amount: 300, Description: "lesson"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := count(&first.ID); got != 2 {
+		t.Errorf("list of the user has %d items after a payment of the user was created, want 2", got)
+	}
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"math"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/tracing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	loginFailureWindow = 15 * time.Minute
+	loginLockDuration  = 15 * time.Minute
+
+	// after accountDelayAfter failures every attempt waits 1s, 2s, 4s ... up to loginMaxDelay
+	accountDelayAfter = 3
+	loginMaxDelay     = 30 * time.Second
+
+	accountLockAfter = 10
+	ipLockAfter      = 50
+
+	lockScopeAccount = "account"
+	lockScopeIP      = "ip"
+)
+
+var (
+	loginFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_failures_total",
+			Help: "Total number of failed login attempts",
+		},
+		[]string{"step"},
+	)
+
+	loginThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_login_throttled_total",
+			Help: "Total number of login attempts rejected by lockout or progressive delay",
+		},
+		[]string{"scope"},
+	)
+
+	loginLockoutsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "auth_lockouts_total",
+			Help: "Total number of temporary lockouts applied",
+		},
+		[]string{"scope"},
+	)
+
+	loginUnlocksTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "auth_unlocks_total",
+			Help: "Total number of lockouts cleared by an admin",
+		},
+	)
+)
+
+// checkLogin rejects the attempt while the account or the ip is locked, or the
+// account progressive delay has not passed yet. Like allowRequest it lets the
+// attempt through when the cache is not available.
+func (fru *futureSiriusUsecase) checkLogin(ctx context.Context, email string, ip string) error {
+	checks := []struct {
+		scope string
+		key   string
+	}{
+		{lockScopeIP, "login_lock_ip_" + ip},
+		{lockScopeAccount, "login_lock_account_" + email},
+		{lockScopeAccount, "login_delay_account_" + email},
+	}
+
+	for _, check := range checks {
+		ttl, err := fru.cache.TTL(ctx, check.key)
+		if err != nil || ttl <= 0 {
+			continue
+		}
+		loginThrottledTotal.WithLabelValues(check.scope).Inc()
+		return &entity.LockedError{RetryAfter: ttl}
+	}
+	return nil
+}
+
+// loginFailed counts the failure for the account and the ip, applies the
+// progressive delay and locks when a threshold is reached
+func (fru *futureSiriusUsecase) loginFailed(ctx context.Context, email string, ip string, step string) {
+	loginFailuresTotal.WithLabelValues(step).Inc()
+
+	if failures, err := fru.cache.Incr(ctx, "login_failures_ip_"+ip, loginFailureWindow); err == nil && failures >= ipLockAfter {
+		fru.lock(ctx, lockScopeIP, "login_lock_ip_"+ip, "login_failures_ip_"+ip)
+	}
+
+	failures, err := fru.cache.Incr(ctx, "login_failures_account_"+email, loginFailureWindow)
+	if err != nil {
+		return
+	}
+
+	if failures >= accountLockAfter {
+		fru.lock(ctx, lockScopeAccount, "login_lock_account_"+email, "login_failures_account_"+email)
+		return
+	}
+
+	if failures >= accountDelayAfter {
+		delay := time.Second * time.Duration(math.Pow(2, float64(failures-accountDelayAfter)))
+		if delay > loginMaxDelay {
+			delay = loginMaxDelay
+		}
+		fru.cache.Set(ctx, "login_delay_account_"+email, 1, delay)
+	}
+}
+
+// loginSucceeded resets the account counters, the ip counter is kept so that
+// one valid account does not hide stuffing from the same ip
+func (fru *futureSiriusUsecase) loginSucceeded(ctx context.Context, email string) {
+	fru.cache.Delete(ctx, "login_failures_account_"+email)
+	fru.cache.Delete(ctx, "login_delay_account_"+email)
+}
+
+func (fru *futureSiriusUsecase) lock(ctx context.Context, scope string, lockKey string, failuresKey string) {
+	if err := fru.cache.Set(ctx, lockKey, 1, loginLockDuration); err != nil {
+		return
+	}
+	fru.cache.Delete(ctx, failuresKey)
+	loginLockoutsTotal.WithLabelValues(scope).Inc()
+}
+
+// UnlockLogin clears lockouts and counters of the account and/or the ip
+func (fru *futureSiriusUsecase) UnlockLogin(ctx context.Context, email string, ip string) error {
+	ctx, span := tracing.Start(ctx, "usecase.UnlockLogin")
+	defer span.End()
+
+	var keys []string
+	if email != "" {
+		email = entity.NormalizeEmail(email)
+		keys = append(keys, "login_lock_account_"+email, "login_delay_account_"+email, "login_failures_account_"+email)
+	}
+	if ip != "" {
+		keys = append(keys, "login_lock_ip_"+ip, "login_failures_ip_"+ip)
+	}
+
+	for _, key := range keys {
+		if err := fru.cache.Delete(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	loginUnlocksTotal.Inc()
+	return nil
+}
@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+	"testing"
+	"time"
+)
+
+func TestLoginLockout(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.activate(t, tu.register(t, 1, "parent"))
+
+	// every failure after the third waits twice as long, up to loginMaxDelay
+	delays := map[int]time.Duration{3: time.Second, 4: 2 * time.Second, 5: 4 * time.Second, 8: 30 * time.Second, 9: 30 * time.Second}
+	for failure := 1; failure < accountLockAfter; failure++ {
+		if _, err := tu.Login(ctx, user.Email, "wrong-password", testIP); !errors.Is(err, entity.ErrInvalidCredentials) {
+			t.Fatalf("failure %d: Login() = %v, want %v", failure, err, entity.ErrInvalidCredentials)
+		}
+
+		ttl, _ := tu.cache.TTL(ctx, "login_delay_account_"+user.Email)
+		want, delayed := delays[failure]
+		switch {
+		case failure < accountDelayAfter && ttl > 0:
+			t.Fatalf("failure %d delays the next attempt by %s", failure, ttl)
+		case delayed && (ttl > want || ttl < want-time.Second):
+			t.Fatalf("failure %d delays the next attempt by %s, want %s", failure, ttl, want)
+		}
+
+		if failure >= accountDelayAfter {
+			var locked *entity.LockedError
+			if _, err := tu.Login(ctx, user.Email, testPassword, testIP); !errors.As(err, &locked) {
+				t.Fatalf("failure %d: Login() during the delay = %v, want a LockedError", failure, err)
+			}
+			// the delay passed
+			tu.cache.Delete(ctx, "login_delay_account_"+user.Email)
+		}
+	}
+
+	if _, err := tu.Login(ctx, user.Email, "wrong-password", testIP); !errors.Is(err, entity.ErrInvalidCredentials) {
+		t.Fatalf("Login() = %v, want %v", err, entity.ErrInvalidCredentials)
+	}
+	var locked *entity.LockedError
+	if _, err := tu.Login(ctx, user.Email, testPassword, testIP); !errors.As(err, &locked) {
+		t.Fatalf("Login() of a locked account = %v, want a LockedError", err)
+	}
+	if locked.RetryAfter <= loginLockDuration-time.Minute || locked.RetryAfter > loginLockDuration {
+		t.Errorf("locked for %s, want %s", locked.RetryAfter, loginLockDuration)
+	}
+	// the lock is per account
+	other := tu.activate(t, tu.register(t, 2, "parent"))
+	if _, err := tu.Login(ctx, other.Email, testPassword, testIP); err != nil {
+		t.Errorf("Login() of another account = %v", err)
+	}
+
+	if err := tu.UnlockLogin(ctx, " "+user.Email+" ", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tu.Login(ctx, user.Email, testPassword, testIP); err != nil {
+		t.Errorf("Login() after UnlockLogin() = %v", err)
+	}
+}
+
+func TestLoginIPLockout(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.activate(t, tu.register(t, 1, "parent"))
+
+	// failures spread over many accounts, none of them is locked
+	for i := 0; i < ipLockAfter; i++ {
+		tu.loginFailed(ctx, "unknown@mail.ru", testIP, "password")
+		tu.cache.Delete(ctx, "login_failures_account_unknown@mail.ru")
+	}
+
+	var locked *entity.LockedError
+	if _, err := tu.Login(ctx, user.Email, testPassword, testIP); !errors.As(err, &locked) {
+		t.Fatalf("Login() from a locked ip = %v, want a LockedError", err)
+	}
+	if _, err := tu.Login(ctx, user.Email, testPassword, "198.51.100.1"); err != nil {
+		t.Errorf("Login() from another ip = %v", err)
+	}
+
+	if err := tu.UnlockLogin(ctx, "", testIP); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tu.Login(ctx, user.Email, testPassword, testIP); err != nil {
+		t.Errorf("Login() after UnlockLogin() = %v", err)
+	}
+}
+
+// Unknown emails, accounts without a password and pending accounts can't log in
+func TestLoginRejects(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	pending := tu.register(t, 1, "parent")
+	legacy := tu.activate(t, tu.register(t, 2, "parent"))
+	if err := tu.db.Model(&entity.User{}).Where("id = ?", legacy.ID).Update("password", "").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		want     error
+	}{
+		{"unknown email", "nobody@mail.ru", testPassword, entity.ErrInvalidCredentials},
+		{"legacy account without a password", legacy.Email, "", entity.ErrInvalidCredentials},
+		{"pending account", pending.Email, testPassword, entity.ErrUserNotActive},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tu.Login(ctx, tt.email, tt.password, testIP); !errors.Is(err, tt.want) {
+				t.Errorf("Login() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Resetting the password revokes the tokens issued before, even the ones
+// whose session is cached
+func TestResetPasswordRevokesTokens(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.activate(t, tu.register(t, 1, "parent"))
+
+	login, err := tu.Login(ctx, user.Email, testPassword, testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tu.Authenticate(ctx, login.Token); err != nil {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+
+	if err := tu.ForgotPassword(ctx, user.Email, testIP); err != nil {
+		t.Fatal(err)
+	}
+	token := tu.lastCode(t, "email", user.Email)
+	if err := tu.ResetPassword(ctx, token, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tu.ResetPassword(ctx, token, "another-password"); err == nil {
+		t.Error("ResetPassword() accepted a used token")
+	}
+
+	if _, err := tu.Authenticate(ctx, login.Token); !errors.Is(err, service.ErrInvalidToken) {
+		t.Errorf("Authenticate() of a token issued before the reset = %v, want %v", err, service.ErrInvalidToken)
+	}
+	if _, err := tu.Login(ctx, user.Email, testPassword, testIP); !errors.Is(err, entity.ErrInvalidCredentials) {
+		t.Errorf("Login() with the old password = %v, want %v", err, entity.ErrInvalidCredentials)
+	}
+	login, err = tu.Login(ctx, user.Email, "new-password", testIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tu.Authenticate(ctx, login.Token); err != nil {
+		t.Errorf("Authenticate() of a new token = %v", err)
+	}
+}
@@ -0,0 +1,129 @@
+package usecase
+
+import (
+	"sirius_future/internal/app/entity"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	linksCreatedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "referral_links_created_total",
+			Help: "Total number of referral links created",
+		},
+	)
+
+	linkRedemptionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "referral_link_redemptions_total",
+			Help: "Total number of referral link redemptions by outcome (success, exhausted, disabled, unknown)",
+		},
+		[]string{"outcome"},
+	)
+
+	referralSignupsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "referral_signups_total",
+			Help: "Total number of users registered with a referral link",
+		},
+	)
+
+	paymentsCreatedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payments_created_total",
+			Help: "Total number of payments created by initial status",
+		},
+		[]string{"status"},
+	)
+
+	paymentTransitionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payment_status_transitions_total",
+			Help: "Total number of payment status changes",
+		},
+		[]string{"from", "to"},
+	)
+
+	revenueTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payments_revenue_total",
+			Help: "Total amount of completed payments by currency",
+		},
+		[]string{"currency"},
+	)
+
+	// counters can't go down, the net revenue is revenue minus reversed
+	revenueReversedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "payments_revenue_reversed_total",
+			Help: "Total amount taken back from the revenue by refunds and edits of completed payments, by currency",
+		},
+		[]string{"currency"},
+	)
+)
+
+// RegisterMetrics registers the usecase metrics in the prometheus registry
+func RegisterMetrics(registerer prometheus.Registerer) {
+	registerer.MustRegister(
+		loginFailuresTotal,
+		loginThrottledTotal,
+		loginLockoutsTotal,
+		loginUnlocksTotal,
+		cacheRequestsTotal,
+		linksCreatedTotal,
+		linkRedemptionsTotal,
+		referralSignupsTotal,
+		paymentsCreatedTotal,
+		paymentTransitionsTotal,
+		revenueTotal,
+		revenueReversedTotal,
+	)
+}
+
+// paymentStatusLabel keeps the label values bounded, statuses are free text
+// in older rows
+func paymentStatusLabel(status string) string {
+	if entity.IsKnownPaymentStatus(status) {
+		return status
+	}
+	return "other"
+}
+
+func recordPaymentCreated(payment *entity.Payment) {
+	paymentsCreatedTotal.WithLabelValues(paymentStatusLabel(payment.Status)).Inc()
+	adjustRevenue(payment.Currency, revenueOf(payment))
+}
+
+// recordPaymentUpdated counts the status change and moves the revenue by what
+// the payment contributes after the update minus what it contributed before:
+// a refund takes the amount back, an edit of a completed payment the difference
+func recordPaymentUpdated(previous *entity.Payment, updated *entity.Payment) {
+	if previous.Status != updated.Status {
+		paymentTransitionsTotal.WithLabelValues(paymentStatusLabel(previous.Status), paymentStatusLabel(updated.Status)).Inc()
+	}
+
+	if previous.Currency == updated.Currency {
+		adjustRevenue(updated.Currency, revenueOf(updated)-revenueOf(previous))
+		return
+	}
+	adjustRevenue(previous.Currency, -revenueOf(previous))
+	adjustRevenue(updated.Currency, revenueOf(updated))
+}
+
+// revenueOf is the amount the payment contributes to the revenue
+func revenueOf(payment *entity.Payment) float64 {
+	if payment.Status != entity.PaymentStatusCompleted || payment.Amount <= 0 {
+		return 0
+	}
+	return payment.Amount
+}
+
+func adjustRevenue(currency string, delta float64) {
+	switch {
+	case delta > 0:
+		revenueTotal.WithLabelValues(currency).Add(delta)
+	case delta < 0:
+		revenueReversedTotal.WithLabelValues(currency).Add(-delta)
+	}
+}
@@ -0,0 +1,156 @@
+package usecase
+
+import (
+	"context"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/app/service"
+	"sirius_future/internal/tracing"
+)
+
+const recoveryCodesCount = 10
+
+// TOTPEnrollment is shown to the user once to set up the authenticator app
+type TOTPEnrollment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+func (fru *futureSiriusUsecase) RequiresMFA(role string) bool {
+	return fru.auth.RequiresMFA(role)
+}
+
+// EnrollTOTP generates a new secret. Two-factor authentication is enabled only
+// after the first code is confirmed with ConfirmTOTP.
+func (fru *futureSiriusUsecase) EnrollTOTP(ctx context.Context, userID uint) (*TOTPEnrollment, error) {
+	ctx, span := tracing.Start(ctx, "usecase.EnrollTOTP")
+	defer span.End()
+
+	user, err := fru.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, entity.ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := fru.totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := fru.totp.EncryptSecret(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fru.repo.SetUserTOTPSecret(ctx, user.ID, encrypted); err != nil {
+		return nil, err
+	}
+
+	fru.invalidate(ctx, tagUsers, tagUser(user.ID))
+
+	return &TOTPEnrollment{
+		Secret:          secret,
+		ProvisioningURI: fru.totp.ProvisioningURI(user.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables two-factor authentication and returns the recovery
+// codes, they are not retrievable later
+func (fru *futureSiriusUsecase) ConfirmTOTP(ctx context.Context, userID uint, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "usecase.ConfirmTOTP")
+	defer span.End()
+
+	user, err := fru.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.TOTPEnabled {
+		return nil, entity.ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, entity.ErrTOTPNotEnrolled
+	}
+
+	secret, err := fru.totp.DecryptSecret(user.TOTPSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := fru.totp.Validate(secret, code, user.TOTPLastStep)
+	if !ok {
+		return nil, entity.ErrTOTPInvalid
+	}
+
+	codes, err := fru.totp.GenerateRecoveryCodes(recoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	hashes := make([]string, 0, len(codes))
+	for _, recoveryCode := range codes {
+		hashes = append(hashes, fru.service.HashToken(service.NormalizeRecoveryCode(recoveryCode)))
+	}
+
+	if err := fru.repo.EnableUserTOTP(ctx, user.ID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	fru.invalidate(ctx, tagUsers, tagUser(user.ID))
+
+	return codes, nil
+}
+
+// LoginMFA finishes the login started by Login with a TOTP or recovery code
+func (fru *futureSiriusUsecase) LoginMFA(ctx context.Context, mfaToken string, code string, ip string) (string, error) {
+	ctx, span := tracing.Start(ctx, "usecase.LoginMFA")
+	defer span.End()
+
+	claims, err := fru.auth.ParseToken(mfaToken)
+	if err != nil {
+		return "", err
+	}
+	if claims.Purpose != service.TokenPurposeMFA {
+		return "", service.ErrInvalidToken
+	}
+
+	user, err := fru.repo.GetUserByID(ctx, claims.UserID)
+	if err != nil || user.TokenVersion != claims.TokenVersion {
+		return "", service.ErrInvalidToken
+	}
+	if !user.TOTPEnabled {
+		return "", entity.ErrTOTPNotEnrolled
+	}
+
+	if err := fru.checkLogin(ctx, user.Email, ip); err != nil {
+		return "", err
+	}
+
+	ok, err := fru.checkSecondFactor(ctx, user, code)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		fru.loginFailed(ctx, user.Email, ip, "mfa")
+		return "", entity.ErrTOTPInvalid
+	}
+
+	fru.loginSucceeded(ctx, user.Email)
+
+	return fru.auth.GenerateToken(user, true)
+}
+
+func (fru *futureSiriusUsecase) checkSecondFactor(ctx context.Context, user *entity.User, code string) (bool, error) {
+	secret, err := fru.totp.DecryptSecret(user.TOTPSecret)
+	if err != nil {
+		return false, err
+	}
+
+	if step, ok := fru.totp.Validate(secret, code, user.TOTPLastStep); ok {
+		return fru.repo.UpdateTOTPLastStep(ctx, user.ID, step)
+	}
+
+	return fru.repo.UseRecoveryCode(ctx, user.ID, fru.service.HashToken(service.NormalizeRecoveryCode(code)))
+}
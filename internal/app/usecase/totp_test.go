@@ -0,0 +1,109 @@
+package usecase
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"strings"
+	"testing"
+	"time"
+)
+
+// totpCodeAt computes the RFC 6238 code the authenticator app shows, offset
+// steps from now
+func totpCodeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(time.Now().Unix()/30+offset))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offsetByte := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offsetByte:offsetByte+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestTOTP(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.activate(t, tu.register(t, 1, "admin"))
+
+	enrollment, err := tu.EnrollTOTP(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed := totpCodeAt(t, enrollment.Secret, 0)
+	if _, err := tu.ConfirmTOTP(ctx, user.ID, wrongCode(confirmed)); !errors.Is(err, entity.ErrTOTPInvalid) {
+		t.Errorf("ConfirmTOTP() with a wrong code = %v, want %v", err, entity.ErrTOTPInvalid)
+	}
+
+	recoveryCodes, err := tu.ConfirmTOTP(ctx, user.ID, confirmed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recoveryCodes) != recoveryCodesCount {
+		t.Fatalf("got %d recovery codes, want %d", len(recoveryCodes), recoveryCodesCount)
+	}
+	if _, err := tu.EnrollTOTP(ctx, user.ID); !errors.Is(err, entity.ErrTOTPAlreadyEnabled) {
+		t.Errorf("EnrollTOTP() when enabled = %v, want %v", err, entity.ErrTOTPAlreadyEnabled)
+	}
+
+	mfaToken := func() string {
+		t.Helper()
+		login, err := tu.Login(ctx, user.Email, testPassword, testIP)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !login.MFARequired || login.Token != "" {
+			t.Fatalf("Login() of a user with two-factor authentication = %+v", login)
+		}
+		return login.MFAToken
+	}
+
+	// the code used to confirm can't be replayed
+	if _, err := tu.LoginMFA(ctx, mfaToken(), confirmed, testIP); !errors.Is(err, entity.ErrTOTPInvalid) {
+		t.Errorf("LoginMFA() with the confirmed code = %v, want %v", err, entity.ErrTOTPInvalid)
+	}
+
+	next := totpCodeAt(t, enrollment.Secret, 1)
+	token, err := tu.LoginMFA(ctx, mfaToken(), next, testIP)
+	if err != nil {
+		t.Fatalf("LoginMFA() with the next code = %v", err)
+	}
+	claims, err := tu.Authenticate(ctx, token)
+	if err != nil || !claims.MFA {
+		t.Errorf("Authenticate() of the mfa token = %+v, %v", claims, err)
+	}
+	if _, err := tu.LoginMFA(ctx, mfaToken(), next, testIP); !errors.Is(err, entity.ErrTOTPInvalid) {
+		t.Errorf("LoginMFA() with a used code = %v, want %v", err, entity.ErrTOTPInvalid)
+	}
+
+	// recovery codes are accepted in any case and with or without dashes, once
+	recovery := strings.ToUpper(strings.ReplaceAll(recoveryCodes[0], "-", ""))
+	if _, err := tu.LoginMFA(ctx, mfaToken(), recovery, testIP); err != nil {
+		t.Errorf("LoginMFA() with a recovery code = %v", err)
+	}
+	if _, err := tu.LoginMFA(ctx, mfaToken(), recoveryCodes[0], testIP); !errors.Is(err, entity.ErrTOTPInvalid) {
+		t.Errorf("LoginMFA() with a used recovery code = %v, want %v", err, entity.ErrTOTPInvalid)
+	}
+	if _, err := tu.LoginMFA(ctx, mfaToken(), recoveryCodes[1], testIP); err != nil {
+		t.Errorf("LoginMFA() with another recovery code = %v", err)
+	}
+
+	// the mfa token is not an access token
+	if _, err := tu.Authenticate(ctx, mfaToken()); err == nil {
+		t.Error("Authenticate() accepted an mfa token")
+	}
+}
@@ -0,0 +1,168 @@
+package usecase
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"sirius_future/internal/app/entity"
+	"sirius_future/internal/tracing"
+	"time"
+)
+
+const (
+	emailTokenTTL      = 24 * time.Hour
+	smsCodeTTL         = 10 * time.Minute
+	maxSMSCodeAttempts = 5
+
+	// a new SMS code keeps the attempts of the previous one issued within the
+	// window, resending doesn't give more guesses
+	smsAttemptsWindow = time.Hour
+
+	resendVerificationWindow  = time.Hour
+	resendVerificationPerUser = 3
+	resendVerificationPerIP   = 20
+)
+
+func (fru *futureSiriusUsecase) VerifyEmail(ctx context.Context, token string) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyEmail")
+	defer span.End()
+
+	verification, err := fru.repo.GetVerificationByHash(ctx, entity.VerificationChannelEmail, fru.service.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+
+	return fru.confirmContact(ctx, verification)
+}
+
+func (fru *futureSiriusUsecase) VerifyPhone(ctx context.Context, userID uint, code string) (*entity.User, error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyPhone")
+	defer span.End()
+
+	verification, err := fru.repo.GetActiveVerification(ctx, userID, entity.VerificationChannelSMS)
+	if err != nil {
+		return nil, err
+	}
+
+	// the attempt is counted before the code is compared
+	allowed, err := fru.repo.AddVerificationAttempt(ctx, verification.ID, maxSMSCodeAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, entity.ErrVerificationAttempts
+	}
+
+	hash := fru.service.HashToken(code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(verification.CodeHash)) != 1 {
+		return nil, entity.ErrVerificationInvalid
+	}
+
+	return fru.confirmContact(ctx, verification)
+}
+
+// ResendVerification issues new codes for every contact that is not confirmed
+// yet, the requests are limited per user and per ip
+func (fru *futureSiriusUsecase) ResendVerification(ctx context.Context, userID uint, ip string) error {
+	ctx, span := tracing.Start(ctx, "usecase.ResendVerification")
+	defer span.End()
+
+	if !fru.allowRequest(ctx, "verify_resend_ip_"+ip, resendVerificationPerIP, resendVerificationWindow) ||
+		!fru.allowRequest(ctx, fmt.Sprintf("verify_resend_user_%d", userID), resendVerificationPerUser, resendVerificationWindow) {
+		return entity.ErrTooManyRequests
+	}
+
+	user, err := fru.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var channels []string
+	if user.EmailVerifiedAt == nil {
+		channels = append(channels, entity.VerificationChannelEmail)
+	}
+	if user.PhoneVerifiedAt == nil {
+		channels = append(channels, entity.VerificationChannelSMS)
+	}
+	if len(channels) == 0 {
+		return entity.ErrUserAlreadyVerified
+	}
+
+	return fru.sendVerification(ctx, user, channels...)
+}
+
+// confirmContact consumes the verification and marks the contact as confirmed.
+// Once both contacts are confirmed the user is activated and the referrer rewarded.
+func (fru *futureSiriusUsecase) confirmContact(ctx context.Context, verification *entity.Verification) (*entity.User, error) {
+	used, err := fru.repo.UseVerification(ctx, verification.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, entity.ErrVerificationInvalid
+	}
+
+	user, err := fru.repo.ConfirmContact(ctx, verification.UserID, verification.Channel, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	fru.invalidate(ctx, tagUsers, tagUser(user.ID))
+
+	return user, nil
+}
+
+func (fru *futureSiriusUsecase) sendVerification(ctx context.Context, user *entity.User, channels ...string) error {
+	for _, channel := range channels {
+		var attempts uint
+		if channel == entity.VerificationChannelSMS {
+			var err error
+			attempts, err = fru.repo.GetVerificationAttempts(ctx, user.ID, channel, time.Now().Add(-smsAttemptsWindow))
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := fru.repo.InvalidateVerifications(ctx, user.ID, channel); err != nil {
+			return err
+		}
+
+		var code string
+		var err error
+		var ttl time.Duration
+		switch channel {
+		case entity.VerificationChannelEmail:
+			code, err = fru.service.GenerateVerificationToken()
+			ttl = emailTokenTTL
+		case entity.VerificationChannelSMS:
+			code, err = fru.service.GenerateOTP()
+			ttl = smsCodeTTL
+		}
+		if err != nil {
+			return err
+		}
+
+		verification := &entity.Verification{
+			UserID:    user.ID,
+			Channel:   channel,
+			CodeHash:  fru.service.HashToken(code),
+			Attempts:  attempts,
+			ExpiresAt: time.Now().Add(ttl),
+		}
+		if err := fru.repo.CreateVerification(ctx, verification); err != nil {
+			return err
+		}
+
+		switch channel {
+		case entity.VerificationChannelEmail:
+			err = fru.notifier.SendEmail(user.Email, "Подтверждение email", fmt.Sprintf("Ваш код подтверждения email: %s", code))
+		case entity.VerificationChannelSMS:
+			err = fru.notifier.SendSMS(user.Phone, fmt.Sprintf("Код подтверждения: %s", code))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to send %s verification: %w", channel, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sirius_future/internal/app/entity"
+	"testing"
+)
+
+// The user is activated once both contacts are confirmed, the cached user
+// lists follow
+func TestVerification(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.register(t, 1, "parent")
+
+	listed := func() string {
+		t.Helper()
+		page, err := tu.ListUsers(ctx, entity.UserFilter{}, entity.PageRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 1 {
+			t.Fatalf("listed %d users, want 1", len(page.Items))
+		}
+		return page.Items[0].Status
+	}
+	if status := listed(); status != entity.UserStatusPending {
+		t.Fatalf("registered user is listed as %s", status)
+	}
+
+	token := tu.lastCode(t, "email", user.Email)
+	confirmed, err := tu.VerifyEmail(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.EmailVerifiedAt == nil || confirmed.Status != entity.UserStatusPending {
+		t.Errorf("user after confirming the email: %+v", confirmed)
+	}
+	if _, err := tu.VerifyEmail(ctx, token); err == nil {
+		t.Error("VerifyEmail() accepted a used token")
+	}
+
+	confirmed, err = tu.VerifyPhone(ctx, user.ID, tu.lastCode(t, "sms", user.Phone))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.PhoneVerifiedAt == nil || confirmed.Status != entity.UserStatusActive {
+		t.Errorf("user after confirming both contacts: %+v", confirmed)
+	}
+	if status := listed(); status != entity.UserStatusActive {
+		t.Errorf("activated user is listed as %s", status)
+	}
+
+	if err := tu.ResendVerification(ctx, user.ID, testIP); !errors.Is(err, entity.ErrUserAlreadyVerified) {
+		t.Errorf("ResendVerification() of a verified user = %v, want %v", err, entity.ErrUserAlreadyVerified)
+	}
+}
+
+// Every SMS code guess is counted, new codes don't reset the count
+func TestVerifyPhoneAttempts(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.register(t, 1, "parent")
+
+	code := tu.lastCode(t, "sms", user.Phone)
+	for attempt := 1; attempt <= maxSMSCodeAttempts; attempt++ {
+		if _, err := tu.VerifyPhone(ctx, user.ID, wrongCode(code)); !errors.Is(err, entity.ErrVerificationInvalid) {
+			t.Fatalf("attempt %d: VerifyPhone() = %v, want %v", attempt, err, entity.ErrVerificationInvalid)
+		}
+	}
+	if _, err := tu.VerifyPhone(ctx, user.ID, code); !errors.Is(err, entity.ErrVerificationAttempts) {
+		t.Fatalf("VerifyPhone() with the right code after all attempts = %v, want %v", err, entity.ErrVerificationAttempts)
+	}
+
+	if err := tu.ResendVerification(ctx, user.ID, testIP); err != nil {
+		t.Fatal(err)
+	}
+	resent := tu.lastCode(t, "sms", user.Phone)
+	if _, err := tu.VerifyPhone(ctx, user.ID, resent); !errors.Is(err, entity.ErrVerificationAttempts) {
+		t.Errorf("VerifyPhone() with a resent code = %v, want %v", err, entity.ErrVerificationAttempts)
+	}
+
+	// the email is confirmed with its own code, it has no attempt budget
+	if _, err := tu.VerifyEmail(ctx, tu.lastCode(t, "email", user.Email)); err != nil {
+		t.Errorf("VerifyEmail() = %v", err)
+	}
+}
+
+func TestResendVerificationLimits(t *testing.T) {
+	tu := newTestUsecase(t)
+	ctx := context.Background()
+	user := tu.register(t, 1, "parent")
+	first := tu.lastCode(t, "email", user.Email)
+
+	for i := 0; i < resendVerificationPerUser; i++ {
+		if err := tu.ResendVerification(ctx, user.ID, testIP); err != nil {
+			t.Fatalf("resend %d: %v", i+1, err)
+		}
+	}
+	if err := tu.ResendVerification(ctx, user.ID, "198.51.100.1"); !errors.Is(err, entity.ErrTooManyRequests) {
+		t.Errorf("ResendVerification() over the user limit = %v, want %v", err, entity.ErrTooManyRequests)
+	}
+
+	// only the last codes are valid
+	sent := tu.notifier.Messages()
+	if len(sent) != 2*(resendVerificationPerUser+1) {
+		t.Fatalf("sent %d messages, want %d", len(sent), 2*(resendVerificationPerUser+1))
+	}
+	if _, err := tu.VerifyEmail(ctx, first); err == nil {
+		t.Error("VerifyEmail() accepted a replaced token")
+	}
+	if _, err := tu.VerifyEmail(ctx, tu.lastCode(t, "email", user.Email)); err != nil {
+		t.Errorf("VerifyEmail() with the last token = %v", err)
+	}
+
+	// the ip limit covers every user
+	for i := 0; i < resendVerificationPerIP; i++ {
+		tu.cache.Incr(ctx, "verify_resend_ip_"+testIP, resendVerificationWindow)
+	}
+	other := tu.register(t, 2, "parent")
+	if err := tu.ResendVerification(ctx, other.ID, testIP); !errors.Is(err, entity.ErrTooManyRequests) {
+		t.Errorf("ResendVerification() over the ip limit = %v, want %v", err, entity.ErrTooManyRequests)
+	}
+	if err := tu.ResendVerification(ctx, other.ID, "198.51.100.2"); err != nil {
+		t.Errorf("ResendVerification() from another ip = %v", err)
+	}
+}
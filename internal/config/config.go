@@ -0,0 +1,512 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+
+	CacheModeRedis  = "redis"
+	CacheModeMemory = "memory"
+	CacheModeTiered = "tiered"
+
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+	TracingExporterFile   = "file"
+
+	RedactMask = "mask"
+	RedactHash = "hash"
+	RedactDrop = "drop"
+	RedactKeep = "keep"
+
+	redacted = "[REDACTED]"
+)
+
+// Config holds all settings of the app. Values are taken from the defaults,
+// then the YAML file, then the environment variables named in the env tags.
+// Fields tagged secret are redacted when the config is printed.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Log       LogConfig       `yaml:"log"`
+	Notifier  NotifierConfig  `yaml:"notifier"`
+	Auth      AuthConfig      `yaml:"auth"`
+	TOTP      TOTPConfig      `yaml:"totp"`
+	Health    HealthConfig    `yaml:"health"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Redaction RedactionConfig `yaml:"redaction"`
+}
+
+type ServerConfig struct {
+	Addr            string        `yaml:"addr" env:"HTTP_ADDR"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"` // how long in-flight requests are drained on shutdown
+}
+
+// DatabaseConfig selects the database driver and tunes the connection pool.
+// Zero pool values keep the database/sql defaults.
+type DatabaseConfig struct {
+	Driver string `yaml:"driver" env:"DB_DRIVER"`
+	DSN    string `yaml:"dsn" env:"DB_DSN"` // file path for sqlite, connection string for postgres, redacted when printed
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+
+	ConnectAttempts int           `yaml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS"` // attempts to connect on startup
+	ConnectBackoff  time.Duration `yaml:"connect_backoff" env:"DB_CONNECT_BACKOFF"`   // delay before the second attempt, doubled after each failure
+
+	AutoMigrate bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"` // apply pending migrations on startup instead of refusing to start
+}
+
+// CacheConfig selects the cache: "redis", "memory" or "tiered", which falls
+// back to memory while redis is down
+type CacheConfig struct {
+	Mode          string        `yaml:"mode" env:"CACHE_MODE"`
+	RedisAddr     string        `yaml:"redis_addr" env:"REDIS_ADDR"`
+	RedisPassword string        `yaml:"redis_password" env:"REDIS_PASSWORD" secret:"true"`
+	RedisDB       int           `yaml:"redis_db" env:"REDIS_DB"`
+	LocalCapacity int           `yaml:"local_capacity" env:"CACHE_LOCAL_CAPACITY"`
+	RetryAfter    time.Duration `yaml:"retry_after" env:"CACHE_RETRY_AFTER"` // how long the tiered cache skips redis after a failure
+}
+
+// LogConfig sets up the log sinks: the file and stdout, each with its own
+// level ("debug", "info", "warn" or "error"), changeable at runtime through
+// the admin endpoint. The file is rotated when it grows over MaxSizeMB or
+// was opened more than MaxAge ago; rotated files are gzipped when Compress is
+// set, at most MaxBackups of them are kept and none older than RetainFor.
+// Zero disables the corresponding limit.
+type LogConfig struct {
+	File        string        `yaml:"file" env:"LOG_FILE"` // empty disables the file sink
+	FileLevel   string        `yaml:"file_level" env:"LOG_FILE_LEVEL"`
+	Stdout      bool          `yaml:"stdout" env:"LOG_STDOUT"`
+	StdoutLevel string        `yaml:"stdout_level" env:"LOG_STDOUT_LEVEL"`
+	MaxSizeMB   int           `yaml:"max_size_mb" env:"LOG_MAX_SIZE_MB"`
+	MaxAge      time.Duration `yaml:"max_age" env:"LOG_MAX_AGE"`
+	MaxBackups  int           `yaml:"max_backups" env:"LOG_MAX_BACKUPS"`
+	RetainFor   time.Duration `yaml:"retain_for" env:"LOG_RETAIN_FOR"`
+	Compress    bool          `yaml:"compress" env:"LOG_COMPRESS"`
+}
+
+type NotifierConfig struct {
+	OutboxFile string `yaml:"outbox_file" env:"NOTIFIER_OUTBOX_FILE"`
+}
+
+type AuthConfig struct {
+	JWTSecret string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true"`
+	TokenTTL  time.Duration `yaml:"token_ttl" env:"JWT_TOKEN_TTL"`
+	MFARoles  []string      `yaml:"mfa_roles" env:"MFA_ROLES"` // users with these roles must pass two-factor authentication
+}
+
+// HealthConfig tunes the readiness checks. The database and the migrations
+// are always critical, redis is critical only when RedisCritical is set,
+// otherwise its outage leaves the app ready but degraded.
+type HealthConfig struct {
+	Timeout       time.Duration `yaml:"timeout" env:"HEALTH_TIMEOUT"` // per dependency
+	RedisCritical bool          `yaml:"redis_critical" env:"HEALTH_REDIS_CRITICAL"`
+}
+
+// TracingConfig selects where spans are exported: "none", "otlp" (HTTP, to
+// OTLPEndpoint), "stdout" or "file" (JSON lines in File) for local use
+type TracingConfig struct {
+	Exporter     string  `yaml:"exporter" env:"TRACING_EXPORTER"`
+	ServiceName  string  `yaml:"service_name" env:"OTEL_SERVICE_NAME"`
+	OTLPEndpoint string  `yaml:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT"` // host:port of the collector
+	OTLPInsecure bool    `yaml:"otlp_insecure" env:"OTEL_EXPORTER_OTLP_INSECURE"`
+	File         string  `yaml:"file" env:"TRACING_FILE"`
+	SampleRatio  float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO"` // share of new traces recorded, callers' decisions are kept
+}
+
+// RedactionConfig sets what happens to personal data in logs and error
+// responses, per kind of data: "mask" keeps a hint (i***@mail.ru), "hash"
+// replaces the value with a keyed hash so equal values can still be
+// correlated, "drop" removes it and "keep" leaves it as is, for local
+// debugging only. Passwords and secrets are always dropped.
+type RedactionConfig struct {
+	Email   string `yaml:"email" env:"REDACT_EMAIL"`
+	Phone   string `yaml:"phone" env:"REDACT_PHONE"`
+	Name    string `yaml:"name" env:"REDACT_NAME"`
+	HashKey string `yaml:"hash_key" env:"REDACT_HASH_KEY" secret:"true"` // required by "hash", phone numbers are easy to brute force otherwise
+}
+
+// TOTPConfig holds the keys encrypting TOTP secrets. EncryptionKey is the
+// base64 encoded 32 byte key with id EncryptionKeyID, OldEncryptionKeys maps
+// ids of retired keys to their base64 value, so secrets written before a
+// rotation can still be read. In the environment they are "id:key,id:key".
+type TOTPConfig struct {
+	Issuer            string            `yaml:"issuer" env:"TOTP_ISSUER"`
+	EncryptionKeyID   string            `yaml:"encryption_key_id" env:"TOTP_ENCRYPTION_KEY_ID"`
+	EncryptionKey     string            `yaml:"encryption_key" env:"TOTP_ENCRYPTION_KEY" secret:"true"`
+	OldEncryptionKeys map[string]string `yaml:"old_encryption_keys" env:"TOTP_ENCRYPTION_OLD_KEYS" secret:"true"`
+}
+
+// Keys decodes the encryption keys by key id
+func (tc TOTPConfig) Keys() (map[string][]byte, error) {
+	keys := map[string][]byte{}
+	decode := func(id, encoded string) error {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("key %q is not valid base64", id)
+		}
+		if len(key) != 32 {
+			return fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+		}
+		keys[id] = key
+		return nil
+	}
+
+	for id, encoded := range tc.OldEncryptionKeys {
+		if err := decode(id, encoded); err != nil {
+			return nil, err
+		}
+	}
+	if err := decode(tc.EncryptionKeyID, tc.EncryptionKey); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func Default() Config {
+	return Config{
+		Server: ServerConfig{Addr: ":8080", ShutdownTimeout: 15 * time.Second},
+		Database: DatabaseConfig{
+			Driver:          DriverSQLite,
+			ConnectAttempts: 5,
+			ConnectBackoff:  time.Second,
+			AutoMigrate:     true,
+		},
+		Cache: CacheConfig{
+			Mode:          CacheModeTiered,
+			RedisAddr:     "localhost:6379",
+			LocalCapacity: 10000,
+			RetryAfter:    5 * time.Second,
+		},
+		Log: LogConfig{
+			File:        "log/sirius_future.log",
+			FileLevel:   "info",
+			StdoutLevel: "info",
+			MaxSizeMB:   100,
+			MaxAge:      24 * time.Hour,
+			MaxBackups:  14,
+			RetainFor:   30 * 24 * time.Hour,
+			Compress:    true,
+		},
+		Notifier: NotifierConfig{OutboxFile: "log/outbox.log"},
+		Auth: AuthConfig{
+			TokenTTL: 24 * time.Hour,
+			MFARoles: []string{"admin", "finance"},
+		},
+		TOTP:   TOTPConfig{Issuer: "SiriusFuture", EncryptionKeyID: "v1"},
+		Health: HealthConfig{Timeout: 2 * time.Second},
+		Tracing: TracingConfig{
+			Exporter:     TracingExporterNone,
+			ServiceName:  "sirius_future",
+			OTLPEndpoint: "localhost:4318",
+			File:         "log/traces.jsonl",
+			SampleRatio:  1,
+		},
+		Redaction: RedactionConfig{
+			Email: RedactMask,
+			Phone: RedactMask,
+			Name:  RedactDrop,
+		},
+	}
+}
+
+// Load reads the YAML file at path over the defaults and applies the
+// environment. An empty path skips the file. The result must be validated
+// before use.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(reflect.ValueOf(&cfg).Elem()); err != nil {
+		return nil, err
+	}
+	cfg.applyDriverDefaults()
+	return &cfg, nil
+}
+
+// applyDriverDefaults fills the settings whose defaults depend on the driver:
+// the sqlite file and the pool for postgres. The postgres DSN has no default.
+func (c *Config) applyDriverDefaults() {
+	if c.Database.Driver == DriverSQLite && c.Database.DSN == "" {
+		c.Database.DSN = "database/test.db"
+	}
+	if c.Database.Driver != DriverPostgres {
+		return
+	}
+	if c.Database.MaxOpenConns == 0 {
+		c.Database.MaxOpenConns = 25
+	}
+	if c.Database.MaxIdleConns == 0 {
+		c.Database.MaxIdleConns = 10
+	}
+	if c.Database.ConnMaxLifetime == 0 {
+		c.Database.ConnMaxLifetime = 30 * time.Minute
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var v validator
+	v.check(c.Server.Addr != "", "server.addr (HTTP_ADDR)", "is required")
+	v.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout (HTTP_SHUTDOWN_TIMEOUT)", "must be positive")
+	c.Database.validate(&v)
+
+	v.check(c.Cache.Mode == CacheModeRedis || c.Cache.Mode == CacheModeMemory || c.Cache.Mode == CacheModeTiered,
+		"cache.mode (CACHE_MODE)", "must be %s, %s or %s, got %q", CacheModeRedis, CacheModeMemory, CacheModeTiered, c.Cache.Mode)
+	v.check(c.Cache.Mode == CacheModeMemory || c.Cache.RedisAddr != "", "cache.redis_addr (REDIS_ADDR)", "is required for cache mode %s", c.Cache.Mode)
+	v.check(c.Cache.LocalCapacity > 0 || c.Cache.Mode == CacheModeRedis, "cache.local_capacity (CACHE_LOCAL_CAPACITY)", "must be positive")
+
+	v.check(c.Log.File != "" || c.Log.Stdout, "log.file (LOG_FILE)", "is required unless log.stdout (LOG_STDOUT) is set")
+	v.check(isLogLevel(c.Log.FileLevel), "log.file_level (LOG_FILE_LEVEL)", "must be debug, info, warn or error, got %q", c.Log.FileLevel)
+	v.check(isLogLevel(c.Log.StdoutLevel), "log.stdout_level (LOG_STDOUT_LEVEL)", "must be debug, info, warn or error, got %q", c.Log.StdoutLevel)
+	v.check(c.Log.MaxSizeMB >= 0, "log.max_size_mb (LOG_MAX_SIZE_MB)", "must not be negative")
+	v.check(c.Log.MaxAge >= 0, "log.max_age (LOG_MAX_AGE)", "must not be negative")
+	v.check(c.Log.MaxBackups >= 0, "log.max_backups (LOG_MAX_BACKUPS)", "must not be negative")
+	v.check(c.Log.RetainFor >= 0, "log.retain_for (LOG_RETAIN_FOR)", "must not be negative")
+	v.check(c.Notifier.OutboxFile != "", "notifier.outbox_file (NOTIFIER_OUTBOX_FILE)", "is required")
+
+	v.check(len(c.Auth.JWTSecret) >= 32, "auth.jwt_secret (JWT_SECRET)",
+		"must be at least 32 characters, generate one with: head -c 32 /dev/urandom | base64")
+	v.check(c.Auth.TokenTTL > 0, "auth.token_ttl (JWT_TOKEN_TTL)", "must be positive")
+
+	v.check(c.TOTP.Issuer != "", "totp.issuer (TOTP_ISSUER)", "is required")
+	v.check(c.TOTP.EncryptionKeyID != "", "totp.encryption_key_id (TOTP_ENCRYPTION_KEY_ID)", "is required")
+	if c.TOTP.EncryptionKey == "" {
+		v.check(false, "totp.encryption_key (TOTP_ENCRYPTION_KEY)", "is required, generate one with: head -c 32 /dev/urandom | base64")
+	} else if _, err := c.TOTP.Keys(); err != nil {
+		v.check(false, "totp.encryption_key (TOTP_ENCRYPTION_KEY)", "%v", err)
+	}
+
+	v.check(c.Health.Timeout > 0, "health.timeout (HEALTH_TIMEOUT)", "must be positive")
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterStdout:
+	case TracingExporterOTLP:
+		v.check(c.Tracing.OTLPEndpoint != "", "tracing.otlp_endpoint (OTEL_EXPORTER_OTLP_ENDPOINT)", "is required for the otlp exporter")
+	case TracingExporterFile:
+		v.check(c.Tracing.File != "", "tracing.file (TRACING_FILE)", "is required for the file exporter")
+	default:
+		v.check(false, "tracing.exporter (TRACING_EXPORTER)", "must be %s, %s, %s or %s, got %q",
+			TracingExporterNone, TracingExporterOTLP, TracingExporterStdout, TracingExporterFile, c.Tracing.Exporter)
+	}
+	for _, policy := range []struct {
+		field  string
+		action string
+	}{
+		{"redaction.email (REDACT_EMAIL)", c.Redaction.Email},
+		{"redaction.phone (REDACT_PHONE)", c.Redaction.Phone},
+		{"redaction.name (REDACT_NAME)", c.Redaction.Name},
+	} {
+		switch policy.action {
+		case RedactMask, RedactDrop, RedactKeep:
+		case RedactHash:
+			v.check(c.Redaction.HashKey != "", "redaction.hash_key (REDACT_HASH_KEY)", "is required for %s: %s", policy.field, RedactHash)
+		default:
+			v.check(false, policy.field, "must be %s, %s, %s or %s, got %q", RedactMask, RedactHash, RedactDrop, RedactKeep, policy.action)
+		}
+	}
+
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio (TRACING_SAMPLE_RATIO)", "must be between 0 and 1")
+
+	return v.err()
+}
+
+// Validate checks only the database settings, the migrate command needs nothing else
+func (dc *DatabaseConfig) Validate() error {
+	var v validator
+	dc.validate(&v)
+	return v.err()
+}
+
+func (dc *DatabaseConfig) validate(v *validator) {
+	v.check(dc.Driver == DriverSQLite || dc.Driver == DriverPostgres,
+		"database.driver (DB_DRIVER)", "must be %s or %s, got %q", DriverSQLite, DriverPostgres, dc.Driver)
+	v.check(dc.DSN != "", "database.dsn (DB_DSN)", "is required")
+	v.check(dc.MaxOpenConns >= 0, "database.max_open_conns (DB_MAX_OPEN_CONNS)", "must not be negative")
+	v.check(dc.MaxIdleConns >= 0, "database.max_idle_conns (DB_MAX_IDLE_CONNS)", "must not be negative")
+	v.check(dc.ConnectAttempts >= 1, "database.connect_attempts (DB_CONNECT_ATTEMPTS)", "must be at least 1")
+}
+
+func isLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+type validator struct {
+	errs []error
+}
+
+func (v *validator) check(ok bool, field string, format string, args ...any) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n%w", errors.Join(v.errs...))
+}
+
+// String prints the config as YAML with the secrets redacted
+func (c Config) String() string {
+	redactedCfg := c
+	redactedCfg.Database.DSN = redactDSN(c.Database.DSN, c.Database.Driver)
+	redactSecrets(reflect.ValueOf(&redactedCfg).Elem())
+
+	data, err := yaml.Marshal(redactedCfg)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+// redactDSN keeps the sqlite file path readable, postgres DSNs carry the password
+func redactDSN(dsn string, driver string) string {
+	if driver == DriverSQLite {
+		return dsn
+	}
+	return redacted
+}
+
+func redactSecrets(v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i)
+
+		if field.Kind() == reflect.Struct {
+			redactSecrets(field)
+			continue
+		}
+		if tag.Tag.Get("secret") != "true" || field.IsZero() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(redacted)
+		case reflect.Map:
+			masked := reflect.MakeMap(field.Type())
+			for _, key := range field.MapKeys() {
+				masked.SetMapIndex(key, reflect.ValueOf(redacted))
+			}
+			field.Set(masked)
+		}
+	}
+}
+
+// applyEnv overrides the fields having an env tag with the set variables
+func applyEnv(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		tag := v.Type().Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := applyEnv(field); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name := tag.Tag.Get("env")
+		if name == "" {
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if err := setField(field, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	switch field.Interface().(type) {
+	case time.Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case string:
+		field.SetString(value)
+	case int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case []string:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case map[string]string:
+		items := map[string]string{}
+		for _, pair := range strings.Split(value, ",") {
+			if strings.TrimSpace(pair) == "" {
+				continue
+			}
+			key, item, found := strings.Cut(pair, ":")
+			if !found {
+				return fmt.Errorf("expected key:value pairs, got %q", pair)
+			}
+			items[strings.TrimSpace(key)] = strings.TrimSpace(item)
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
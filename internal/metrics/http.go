@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+// unmatchedRoute labels requests no route matched, so scanners probing random
+// paths don't create new series
+const unmatchedRoute = "unmatched"
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Total number of HTTP requests",
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	httpRequestDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Duration of HTTP requests in seconds",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	httpRequestsInFlight = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "Number of HTTP requests being served",
+		},
+	)
+
+	httpResponseSize = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "http_response_size_bytes",
+			Help:    "Size of HTTP response bodies in bytes",
+			Buckets: prometheus.ExponentialBuckets(100, 10, 6), // 100B to 10MB
+		},
+		[]string{"route", "method"},
+	)
+)
+
+func RegisterHTTPMetrics(reg prometheus.Registerer) {
+	reg.MustRegister(httpRequestsTotal, httpRequestDuration, httpRequestsInFlight, httpResponseSize)
+}
+
+// Middleware records the HTTP metrics labelled by the matched route template,
+// e.g. "/api/payments/user/:id", never by the raw path. It must be the first
+// middleware so it sees every request, errors are rendered by the middleware
+// after it, see handler.RenderErrors.
+func Middleware(c *fiber.Ctx) error {
+	start := time.Now()
+	httpRequestsInFlight.Inc()
+	defer httpRequestsInFlight.Dec()
+
+	self := c.Route()
+	err := c.Next()
+
+	// when no route matched, the route is still the one of this middleware
+	route := unmatchedRoute
+	if matched := c.Route(); matched != self {
+		route = matched.Path
+	}
+	// fiber reuses the buffer behind Method, the label must be copied
+	method := utils.CopyString(c.Method())
+	status := strconv.Itoa(c.Response().StatusCode())
+
+	httpRequestsTotal.WithLabelValues(route, method, status).Inc()
+	httpRequestDuration.WithLabelValues(route, method, status).Observe(time.Since(start).Seconds())
+	httpResponseSize.WithLabelValues(route, method).Observe(float64(len(c.Response().Body())))
+	return err
+}
+
+// Handler serves the metrics of the default registry
+func Handler(c *fiber.Ctx) error {
+	fasthttpadaptor.NewFastHTTPHandler(promhttp.Handler())(c.Context())
+	return nil
+}
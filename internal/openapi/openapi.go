@@ -0,0 +1,268 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Operation documents one route. Request and Response are values of the
+// DTOs, their schemas are generated from the types so they stay in sync
+// with the handlers.
+type Operation struct {
+	Method  string
+	Path    string // fiber syntax, /api/payments/:id
+	Tag     string
+	Summary string
+
+	Auth        bool // needs a bearer token
+	Query       []Parameter
+	Request     any
+	Status      int         // of the successful response, 200 when not set
+	Response    any         // nil for an empty body
+	ContentType string      // of the response, application/json when not set
+	Errors      []int       // statuses of the error responses besides 500
+	Other       map[int]any // responses with their own body, e.g. 503 of the readiness probe
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// Query documents a query parameter of the type: string, integer, number,
+// boolean or date-time
+func Query(name string, kind string, description string) Parameter {
+	schema := &Schema{Type: kind}
+	if kind == "date-time" {
+		schema = &Schema{Type: "string", Format: kind}
+	}
+	return Parameter{Name: name, In: "query", Description: description, Schema: schema}
+}
+
+// Document is an OpenAPI 3.0 document
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+
+	body []byte
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type operation struct {
+	Tags        []string            `json:"tags,omitempty"`
+	Summary     string              `json:"summary,omitempty"`
+	OperationID string              `json:"operationId"`
+	Security    []map[string][]any  `json:"security,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *requestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]response `json:"responses"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]securityScheme `json:"securitySchemes"`
+}
+
+type securityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat"`
+}
+
+const bearerAuth = "bearerAuth"
+
+// New builds the document, errorResponse is the body of every error response
+func New(info Info, errorResponse any, operations ...Operation) (*Document, error) {
+	schemas := schemas{}
+	errorSchema := schemas.of(reflect.TypeOf(errorResponse))
+
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]operation{},
+		Components: components{
+			Schemas: schemas,
+			SecuritySchemes: map[string]securityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	for _, op := range operations {
+		path, parameters := pathParameters(op.Path)
+		method := strings.ToLower(op.Method)
+		if _, found := doc.Paths[path][method]; found {
+			return nil, fmt.Errorf("openapi: %s %s is documented twice", op.Method, op.Path)
+		}
+
+		documented := operation{
+			Summary:     op.Summary,
+			OperationID: operationID(method, path),
+			Parameters:  append(parameters, op.Query...),
+			Responses:   map[string]response{},
+		}
+		if op.Tag != "" {
+			documented.Tags = []string{op.Tag}
+		}
+		if op.Auth {
+			documented.Security = []map[string][]any{{bearerAuth: {}}}
+		}
+		if op.Request != nil {
+			documented.RequestBody = &requestBody{
+				Required: true,
+				Content:  jsonContent(schemas.of(reflect.TypeOf(op.Request))),
+			}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := response{Description: http.StatusText(status)}
+		if op.Response != nil {
+			contentType := op.ContentType
+			if contentType == "" {
+				contentType = fiber.MIMEApplicationJSON
+			}
+			success.Content = map[string]mediaType{contentType: {Schema: schemas.of(reflect.TypeOf(op.Response))}}
+		}
+		documented.Responses[strconv.Itoa(status)] = success
+
+		for _, errorStatus := range append(op.Errors, http.StatusInternalServerError) {
+			documented.Responses[strconv.Itoa(errorStatus)] = response{
+				Description: http.StatusText(errorStatus),
+				Content:     jsonContent(errorSchema),
+			}
+		}
+
+		for otherStatus, body := range op.Other {
+			documented.Responses[strconv.Itoa(otherStatus)] = response{
+				Description: http.StatusText(otherStatus),
+				Content:     jsonContent(schemas.of(reflect.TypeOf(body))),
+			}
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]operation{}
+		}
+		doc.Paths[path][method] = documented
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	doc.body = body
+	return doc, nil
+}
+
+func jsonContent(schema *Schema) map[string]mediaType {
+	return map[string]mediaType{fiber.MIMEApplicationJSON: {Schema: schema}}
+}
+
+// pathParameters converts /payments/:id to /payments/{id}, ids are integers
+func pathParameters(path string) (string, []Parameter) {
+	var parameters []Parameter
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		name, found := strings.CutPrefix(segment, ":")
+		if !found {
+			continue
+		}
+		schema := &Schema{Type: "string"}
+		if name == "id" {
+			schema = &Schema{Type: "integer", Format: "int64", Minimum: float(1)}
+		}
+		parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+		segments[i] = "{" + name + "}"
+	}
+	return strings.Join(segments, "/"), parameters
+}
+
+// operationID is derived from the route, get /api/payments/{id} is "getApiPaymentsId"
+func operationID(method string, path string) string {
+	var id strings.Builder
+	id.WriteString(method)
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		id.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return id.String()
+}
+
+// Check compares the routes of the app with the document, every route must
+// be documented and every documented route must exist. HEAD routes, which
+// fiber adds for GET routes, and the routes in skip are not checked.
+func (doc *Document) Check(app *fiber.App, skip ...string) error {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+
+	registered := map[string]bool{}
+	var undocumented []string
+	for _, route := range app.GetRoutes(true) {
+		if route.Method == fiber.MethodHead || skipped[route.Path] {
+			continue
+		}
+		path, _ := pathParameters(route.Path)
+		method := strings.ToLower(route.Method)
+		registered[method+" "+path] = true
+		if _, found := doc.Paths[path][method]; !found {
+			undocumented = append(undocumented, route.Method+" "+route.Path)
+		}
+	}
+
+	var missing []string
+	for path, operations := range doc.Paths {
+		for method := range operations {
+			if !registered[method+" "+path] {
+				missing = append(missing, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	if len(undocumented) == 0 && len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(undocumented)
+	sort.Strings(missing)
+	return fmt.Errorf("openapi: undocumented routes %v, documented routes that don't exist %v", undocumented, missing)
+}
+
+// Handler serves the document as JSON
+func (doc *Document) Handler(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	return c.Send(doc.body)
+}
@@ -0,0 +1,214 @@
+package openapi
+
+import (
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of the OpenAPI 3.0 schema object the API needs
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     bool               `json:"exclusiveMinimum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	// "Page[sirius_future/internal/app/dto.UserResponse]" is named "PageUserResponse"
+	packagePath = regexp.MustCompile(`[\w./-]+\.`)
+	nameSymbols = regexp.MustCompile(`[^A-Za-z0-9]`)
+)
+
+// ruleDescriptions explain the database rules like service.RuleUserExists,
+// the standard rules are turned into schema constraints
+var ruleDescriptions = map[string]string{
+	"user_exists": "must refer to an existing user",
+}
+
+// schemas collects the named schemas of the document while the types are walked
+type schemas map[string]*Schema
+
+// of returns the schema of the type, named structs are added to the
+// components and referenced
+func (s schemas) of(rt reflect.Type) *Schema {
+	switch rt.Kind() {
+	case reflect.Pointer:
+		schema := s.of(rt.Elem())
+		if schema.Ref != "" {
+			// siblings of $ref are ignored in 3.0
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: s.of(rt.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.of(rt.Elem())}
+	case reflect.Struct:
+		if rt == timeType {
+			return &Schema{Type: "string", Format: "date-time"}
+		}
+		if rt.Name() == "" {
+			return s.object(rt)
+		}
+		name := schemaName(rt)
+		if _, found := s[name]; !found {
+			s[name] = nil // guards recursive types
+			s[name] = s.object(rt)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+func schemaName(rt reflect.Type) string {
+	return nameSymbols.ReplaceAllString(packagePath.ReplaceAllString(rt.Name(), ""), "")
+}
+
+// object describes the fields the way encoding/json names them
+func (s schemas) object(rt reflect.Type) *Schema {
+	object := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	// validate rules name the other fields by their go names
+	names := map[string]string{}
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if name, ok := jsonName(field); ok {
+			names[field.Name] = name
+		}
+	}
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		name, ok := jsonName(field)
+		if !ok {
+			continue
+		}
+
+		schema := s.of(field.Type)
+		if constrain(schema, field.Tag.Get("validate"), names) {
+			object.Required = append(object.Required, name)
+		}
+		object.Properties[name] = schema
+	}
+	return object
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if !field.IsExported() || tag == "-" {
+		return "", false
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = field.Name
+	}
+	return name, true
+}
+
+// constrain turns the validate rules into constraints of the schema and
+// reports whether the field is required, names maps the go names of the
+// fields to their JSON names
+func constrain(schema *Schema, rules string, names map[string]string) bool {
+	if rules == "" || schema.Ref != "" {
+		return false
+	}
+
+	required := false
+	var descriptions []string
+	for _, rule := range strings.Split(rules, ",") {
+		tag, param, _ := strings.Cut(rule, "=")
+		switch tag {
+		case "required":
+			required = true
+		case "required_without", "required_without_all":
+			var fields []string
+			for _, field := range strings.Fields(param) {
+				fields = append(fields, names[field])
+			}
+			descriptions = append(descriptions, "required when "+strings.Join(fields, " and ")+" are empty")
+		case "oneof":
+			schema.Enum = strings.Fields(param)
+		case "min", "max", "gt", "gte":
+			limit, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			bound(schema, tag, limit)
+		case "email":
+			schema.Format = "email"
+		case "ip":
+			schema.Format = "ip"
+		case "e164":
+			schema.Pattern = `^\+[1-9][0-9]{1,14}$`
+		case "iso4217":
+			schema.Pattern = `^[A-Z]{3}$`
+		case "ne":
+			descriptions = append(descriptions, "must not be "+param)
+		default:
+			if description, ok := ruleDescriptions[tag]; ok {
+				descriptions = append(descriptions, description)
+			}
+		}
+	}
+	schema.Description = strings.Join(descriptions, "; ")
+	return required
+}
+
+func bound(schema *Schema, tag string, limit int) {
+	if schema.Type == "string" {
+		switch tag {
+		case "min", "gte":
+			schema.MinLength = &limit
+		case "max":
+			schema.MaxLength = &limit
+		case "gt":
+			limit++
+			schema.MinLength = &limit
+		}
+		return
+	}
+
+	switch tag {
+	case "min", "gte":
+		schema.Minimum = float(limit)
+	case "max":
+		schema.Maximum = float(limit)
+	case "gt":
+		schema.Minimum = float(limit)
+		schema.ExclusiveMinimum = true
+	}
+}
+
+func float(value int) *float64 {
+	result := float64(value)
+	return &result
+}
@@ -0,0 +1,36 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// swaggerUIVersion pins the assets loaded from the CDN
+const swaggerUIVersion = "5.17.14"
+
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="utf-8">
+	<title>%[1]s</title>
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui.css">
+</head>
+<body>
+	<div id="swagger-ui"></div>
+	<script src="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui-bundle.js" crossorigin></script>
+	<script>
+		window.ui = SwaggerUIBundle({url: %[3]q, dom_id: "#swagger-ui", persistAuthorization: true});
+	</script>
+</body>
+</html>`
+
+// UI serves Swagger UI for the document published at specURL
+func UI(title string, specURL string) fiber.Handler {
+	page := fmt.Sprintf(swaggerUI, html.EscapeString(title), swaggerUIVersion, specURL)
+	return func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.SendString(page)
+	}
+}
@@ -0,0 +1,28 @@
+package redact
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ErrorResponses wraps the error handler of the app and redacts the JSON
+// responses it renders, their messages may quote values of the request
+func (p *Policy) ErrorResponses(next fiber.ErrorHandler) fiber.ErrorHandler {
+	return func(c *fiber.Ctx, err error) error {
+		if err := next(c, err); err != nil {
+			return err
+		}
+
+		response := c.Response()
+		if !strings.HasPrefix(string(response.Header.ContentType()), fiber.MIMEApplicationJSON) {
+			return nil
+		}
+
+		body := string(response.Body())
+		if redactedBody := p.Text(body); redactedBody != body {
+			response.SetBodyString(redactedBody)
+		}
+		return nil
+	}
+}
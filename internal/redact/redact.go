@@ -0,0 +1,244 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"regexp"
+	"sirius_future/internal/config"
+	"strings"
+	"sync"
+)
+
+// Kinds of personal data, set on struct fields with the pii tag:
+//
+//	Email string `json:"email" pii:"email"`
+const (
+	KindEmail  = "email"
+	KindPhone  = "phone"
+	KindName   = "name"
+	KindSecret = "secret" // always dropped
+)
+
+const redacted = "[REDACTED]"
+
+// Free text can't be tagged, emails and E.164 phones are recognized in it.
+// Names aren't, they must not be put in messages.
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	phonePattern = regexp.MustCompile(`\+[1-9][0-9]{9,14}`)
+)
+
+// Policy redacts personal data according to the configured action per kind
+type Policy struct {
+	actions map[string]string
+	hashKey []byte
+
+	sensitiveTypes sync.Map // reflect.Type -> bool, see sensitive
+}
+
+func NewPolicy(cfg config.RedactionConfig) *Policy {
+	return &Policy{
+		actions: map[string]string{
+			KindEmail:  cfg.Email,
+			KindPhone:  cfg.Phone,
+			KindName:   cfg.Name,
+			KindSecret: config.RedactDrop,
+		},
+		hashKey: []byte(cfg.HashKey),
+	}
+}
+
+// Field redacts a value of the kind, false means the value must be left out
+func (p *Policy) Field(kind string, value string) (string, bool) {
+	if value == "" {
+		return "", kind != KindSecret
+	}
+
+	action, ok := p.actions[kind]
+	if !ok {
+		action = config.RedactDrop
+	}
+	switch action {
+	case config.RedactKeep:
+		return value, true
+	case config.RedactMask:
+		return mask(kind, value), true
+	case config.RedactHash:
+		return p.hash(value), true
+	default:
+		return "", false
+	}
+}
+
+// Text redacts the emails and phones found in free text, e.g. error messages
+func (p *Policy) Text(text string) string {
+	text = p.replace(text, emailPattern, KindEmail)
+	return p.replace(text, phonePattern, KindPhone)
+}
+
+func (p *Policy) replace(text string, pattern *regexp.Regexp, kind string) string {
+	if p.actions[kind] == config.RedactKeep {
+		return text
+	}
+	return pattern.ReplaceAllStringFunc(text, func(value string) string {
+		if value, ok := p.Field(kind, value); ok {
+			return value
+		}
+		return redacted
+	})
+}
+
+// Value returns v with its tagged fields redacted, as a map keyed by the JSON
+// names of the fields. Values of types without tagged fields are returned as is.
+func (p *Policy) Value(v any) any {
+	if v == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !p.sensitive(rv.Type()) {
+		return v
+	}
+	return p.walk(rv)
+}
+
+func (p *Policy) walk(rv reflect.Value) any {
+	switch rv.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return p.walk(rv.Elem())
+	case reflect.Struct:
+		fields := map[string]any{}
+		p.fields(rv, fields)
+		return fields
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil
+		}
+		items := make([]any, rv.Len())
+		for i := range items {
+			items[i] = p.walk(rv.Index(i))
+		}
+		return items
+	case reflect.String:
+		return p.Text(rv.String())
+	default:
+		return rv.Interface()
+	}
+}
+
+// fields adds the fields of the struct the way encoding/json names them,
+// embedded structs without a JSON name are flattened
+func (p *Policy) fields(rv reflect.Value, fields map[string]any) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, skip := jsonName(field)
+		if skip {
+			continue
+		}
+		value := rv.Field(i)
+
+		if field.Anonymous && name == "" {
+			if value.Kind() == reflect.Pointer {
+				if value.IsNil() {
+					continue
+				}
+				value = value.Elem()
+			}
+			if value.Kind() == reflect.Struct {
+				p.fields(value, fields)
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if kind := field.Tag.Get("pii"); kind != "" {
+			if value.Kind() == reflect.String {
+				if redactedValue, ok := p.Field(kind, value.String()); ok {
+					fields[name] = redactedValue
+				}
+			}
+			continue
+		}
+
+		if p.sensitive(field.Type) || value.Kind() == reflect.String {
+			fields[name] = p.walk(value)
+		} else {
+			fields[name] = value.Interface()
+		}
+	}
+}
+
+func jsonName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name, false
+}
+
+// sensitive reports whether values of the type may hold tagged fields
+func (p *Policy) sensitive(rt reflect.Type) bool {
+	if cached, ok := p.sensitiveTypes.Load(rt); ok {
+		return cached.(bool)
+	}
+	result := hasTaggedFields(rt, map[reflect.Type]bool{})
+	p.sensitiveTypes.Store(rt, result)
+	return result
+}
+
+// hasTaggedFields walks the type once, visited guards recursive types
+func hasTaggedFields(rt reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[rt] {
+		return false
+	}
+	visited[rt] = true
+
+	switch rt.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		return hasTaggedFields(rt.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < rt.NumField(); i++ {
+			field := rt.Field(i)
+			if field.IsExported() && (field.Tag.Get("pii") != "" || hasTaggedFields(field.Type, visited)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *Policy) hash(value string) string {
+	mac := hmac.New(sha256.New, p.hashKey)
+	mac.Write([]byte(value))
+	return "sha256:" + hex.EncodeToString(mac.Sum(nil))[:16]
+}
+
+// mask keeps a hint of the value: i***@mail.ru, ***4567, I***
+func mask(kind string, value string) string {
+	switch kind {
+	case KindEmail:
+		if at := strings.LastIndex(value, "@"); at > 0 {
+			return value[:1] + "***" + value[at:]
+		}
+	case KindPhone:
+		if len(value) > 8 {
+			return "***" + value[len(value)-4:]
+		}
+	case KindName:
+		for _, r := range value {
+			return string(r) + "***"
+		}
+	}
+	return "***"
+}
@@ -0,0 +1,64 @@
+package redact
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Handler redacts the attributes of every record before passing it to next:
+// tagged fields of structs, and emails and phones in strings and errors
+func (p *Policy) Handler(next slog.Handler) slog.Handler {
+	return &handler{next: next, policy: p}
+}
+
+type handler struct {
+	next   slog.Handler
+	policy *Policy
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, record slog.Record) error {
+	redactedRecord := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redactedRecord.AddAttrs(h.policy.attr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redactedRecord)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redactedAttrs := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redactedAttrs[i] = h.policy.attr(attr)
+	}
+	return &handler{next: h.next.WithAttrs(redactedAttrs), policy: h.policy}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name), policy: h.policy}
+}
+
+func (p *Policy) attr(attr slog.Attr) slog.Attr {
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, p.Text(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redactedGroup := make([]any, len(group))
+		for i, member := range group {
+			redactedGroup[i] = p.attr(member)
+		}
+		return slog.Group(attr.Key, redactedGroup...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, p.Text(err.Error()))
+		}
+		return slog.Any(attr.Key, p.Value(value.Any()))
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
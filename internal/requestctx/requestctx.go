@@ -0,0 +1,108 @@
+package requestctx
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HeaderRequestID is accepted from the caller and echoed in every response
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds the accepted ID, longer or non-printable values
+// are replaced so callers can't inject arbitrary data into the logs
+const maxRequestIDLength = 128
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	userIDKey
+	routeKey
+)
+
+// Middleware takes the request ID from X-Request-ID or generates one, puts it
+// in the request context and echoes it in the response. It must run before
+// the handlers that log, after the tracing middleware so the span gets the ID.
+func Middleware(c *fiber.Ctx) error {
+	id := c.Get(HeaderRequestID)
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	} else {
+		// fiber reuses the buffer behind the header value
+		id = utils.CopyString(id)
+	}
+
+	c.Set(HeaderRequestID, id)
+	trace.SpanFromContext(c.UserContext()).SetAttributes(attribute.String("http.request.id", id))
+	c.SetUserContext(WithRequestID(c.UserContext(), id))
+	return c.Next()
+}
+
+// RecordRoutes makes every registered route put its template in the request
+// context before its handlers run. Middleware can't know the route, it is
+// matched after them. Must be called once all routes are registered.
+func RecordRoutes(app *fiber.App) {
+	seen := make(map[*fiber.Route]bool)
+	for _, routes := range app.Stack() {
+		for _, route := range routes {
+			if seen[route] {
+				continue
+			}
+			seen[route] = true
+
+			path := route.Path
+			record := func(c *fiber.Ctx) error {
+				c.SetUserContext(WithRoute(c.UserContext(), path))
+				return c.Next()
+			}
+			route.Handlers = append([]fiber.Handler{record}, route.Handlers...)
+		}
+	}
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestID returns the ID of the request, empty outside of requests
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+func WithUserID(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, userIDKey, id)
+}
+
+// UserID returns the authenticated user of the request, false for anonymous ones
+func UserID(ctx context.Context) (uint, bool) {
+	id, ok := ctx.Value(userIDKey).(uint)
+	return id, ok
+}
+
+func WithRoute(ctx context.Context, route string) context.Context {
+	return context.WithValue(ctx, routeKey, route)
+}
+
+// Route returns the template of the matched route, e.g. /api/payments/:id
+func Route(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey).(string)
+	return route
+}
@@ -0,0 +1,49 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Middleware starts the server span of the request, continuing the trace of
+// the caller when it sends traceparent. Handlers pass c.UserContext() on so
+// the spans of the lower layers become children of this one. Errors are
+// rendered by a later middleware, the status is read from the response.
+func Middleware(c *fiber.Ctx) error {
+	carrier := propagation.HeaderCarrier{}
+	c.Request().Header.VisitAll(func(key, value []byte) {
+		carrier.Set(string(key), string(value))
+	})
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+
+	// fiber reuses the buffer behind Method, the attribute must be copied
+	method := utils.CopyString(c.Method())
+	ctx, span := Tracer().Start(ctx, method, trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(semconv.HTTPRequestMethodKey.String(method)))
+	defer span.End()
+
+	c.SetUserContext(ctx)
+	self := c.Route()
+	err := c.Next()
+
+	// the route is known after routing, the span is named after its template
+	if route := c.Route(); route != self {
+		span.SetName(method + " " + route.Path)
+		span.SetAttributes(semconv.HTTPRoute(route.Path))
+	}
+
+	status := c.Response().StatusCode()
+	span.SetAttributes(attribute.Int(string(semconv.HTTPResponseStatusCodeKey), status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// InstrumentGORM wraps every query in a client span with the SQL statement.
+// Queries must be run with db.WithContext(ctx) to join the request trace.
+func InstrumentGORM(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", beforeQuery("create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", afterQuery),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", beforeQuery("query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", afterQuery),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", beforeQuery("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", afterQuery),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", beforeQuery("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", afterQuery),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", beforeQuery("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", afterQuery),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", beforeQuery("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", afterQuery),
+	)
+}
+
+func beforeQuery(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement.Context == nil {
+			return
+		}
+		ctx, span := Tracer().Start(db.Statement.Context, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemKey.String(db.Dialector.Name()),
+				semconv.DBOperationName(operation),
+				semconv.DBCollectionName(db.Statement.Table),
+			))
+		db.Statement.Context = ctx
+		db.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func afterQuery(db *gorm.DB) {
+	value, ok := db.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	span.SetAttributes(
+		semconv.DBQueryText(db.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		RecordError(span, db.Error)
+	}
+}